@@ -0,0 +1,41 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"fmt"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ValidateAPNG checks the animation control structure of the PNG data: the
+// acTL chunk must appear exactly once, before the first IDAT and every frame
+// chunk (fcTL, fdAT).  Frame chunks without an acTL chunk are rejected.  Files
+// which are not animated pass.
+func ValidateAPNG(data []byte) error {
+	chunks, err := scanChunks(data)
+	if err != nil {
+		return err
+	}
+
+	actl := indexOfChunk(chunks, "acTL")
+	for i, c := range chunks {
+		switch c.ct {
+		case "acTL":
+			if i != actl {
+				return errors.New("APNG has more than one acTL chunk")
+			}
+		case "fcTL", "fdAT":
+			if actl < 0 {
+				return fmt.Errorf("APNG %s chunk at offset %d without an acTL chunk", c.ct, c.offset)
+			}
+			fallthrough
+		case "IDAT":
+			if actl > i {
+				return fmt.Errorf("APNG %s chunk at offset %d precedes acTL", c.ct, c.offset)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// apngPNG returns the red fixture turned into a two frame animation, with a
+// tEXt chunk between the frames:
+//
+//	IHDR acTL fcTL IDAT fcTL fdAT tEXt IEND
+func apngPNG(t *testing.T) []byte {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+	chunks, err := scanChunks(bs)
+	fatalIfError(t, err)
+
+	raw := func(ct string, data []byte) []byte {
+		c := make([]byte, 8, 12+len(data))
+		binary.BigEndian.PutUint32(c, uint32(len(data)))
+		copy(c[4:], ct)
+		c = append(c, data...)
+		return binary.BigEndian.AppendUint32(c, crc32.ChecksumIEEE(c[4:]))
+	}
+	fctl := func(seq uint32) []byte {
+		d := make([]byte, 26)
+		binary.BigEndian.PutUint32(d, seq)
+		binary.BigEndian.PutUint32(d[4:], 16)
+		binary.BigEndian.PutUint32(d[8:], 16)
+		return raw("fcTL", d)
+	}
+	idat := chunks[1].data(bs)
+	text, _ := buildChunk("tEXt", formatTEXTChunk([]byte("between"), "Frame"))
+
+	out := append([]byte{}, bs[:chunks[1].offset]...)
+	out = append(out, raw("acTL", []byte{0, 0, 0, 2, 0, 0, 0, 0})...)
+	out = append(out, fctl(0)...)
+	out = append(out, chunks[1].raw(bs)...)
+	out = append(out, fctl(1)...)
+	out = append(out, raw("fdAT", append([]byte{0, 0, 0, 2}, idat...))...)
+	out = append(out, text...)
+	return append(out, chunks[2].raw(bs)...)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestEmbedAPNG(t *testing.T) {
+	anim := apngPNG(t)
+	fatalIfError(t, ValidateAPNG(anim))
+
+	frames := func(cts []string) string {
+		fs := []string{}
+		for _, ct := range cts {
+			switch ct {
+			case "acTL", "fcTL", "IDAT", "fdAT":
+				fs = append(fs, ct)
+			}
+		}
+		return strings.Join(fs, ",")
+	}
+	exp := frames(chunkTypes(t, anim))
+
+	for _, opts := range [][]Option{nil, {WithPlacement(GroupWithExistingText)}} {
+		out, err := EmbedTEXT(anim, "Key", "Value", opts...)
+		fatalIfError(t, err)
+		fatalIfError(t, ValidateAPNG(out))
+
+		cts := chunkTypes(t, out)
+		if cts[1] != "tEXt" || frames(cts) != exp {
+			t.Errorf("Expected tEXt before acTL and frames intact, got %v\n", cts)
+		}
+	}
+}
+
+func TestValidateAPNG(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+	fatalIfError(t, ValidateAPNG(bs))
+
+	anim := apngPNG(t)
+	chunks, err := scanChunks(anim)
+	fatalIfError(t, err)
+	actl := chunks[indexOfChunk(chunks, "acTL")]
+
+	// Move acTL behind the first IDAT.
+	late := append([]byte{}, anim[:actl.offset]...)
+	late = append(late, anim[actl.end():chunks[3].end()]...)
+	late = append(late, actl.raw(anim)...)
+	late = append(late, anim[chunks[3].end():]...)
+
+	// Drop acTL altogether.
+	missing := append(append([]byte{}, anim[:actl.offset]...), anim[actl.end():]...)
+
+	for _, data := range [][]byte{late, missing} {
+		if err := ValidateAPNG(data); err == nil {
+			t.Errorf("Expected error, got nil!\n")
+		}
+	}
+}
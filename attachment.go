@@ -0,0 +1,87 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// AttachmentPrefix is the keyword prefix of iTXt chunks which carry binary
+// attachments.  The attachment name follows the prefix, and the chunk text is
+// the standard base64 encoding of the attachment bytes.
+const AttachmentPrefix = "pngembed:attachment:"
+
+////////////////////////////////////////////////////////////////////////////////
+
+// EmbedAttachment base64-encodes `blob` and stores it in an iTXt chunk keyed
+// `pngembed:attachment:<name>`.
+func EmbedAttachment(data []byte, name string, blob []byte) ([]byte, error) {
+	if len(name) == 0 {
+		return nil, fmt.Errorf("invalid attachment name (%s)", name)
+	}
+	k := AttachmentPrefix + name
+	if err := ValidateKeyword(k); err != nil {
+		return nil, err
+	}
+	return EmbedITXT(data, k, base64.StdEncoding.EncodeToString(blob))
+}
+
+// ExtractAttachment returns the decoded bytes of the attachment called `name`.
+func ExtractAttachment(data []byte, name string) ([]byte, error) {
+	m, err := ExtractITXT(data)
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := m[AttachmentPrefix+name]
+	if !ok {
+		return nil, fmt.Errorf("attachment (%s) not found", name)
+	}
+	return base64.StdEncoding.DecodeString(string(v))
+}
+
+// ListAttachments returns the sorted names of all attachments in the PNG data.
+func ListAttachments(data []byte) ([]string, error) {
+	m, err := ExtractITXT(data)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for k := range m {
+		if strings.HasPrefix(k, AttachmentPrefix) {
+			names = append(names, strings.TrimPrefix(k, AttachmentPrefix))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ExtractAllDecoded is like `ExtractAll` but decodes attachments: the value of
+// every keyword starting with `AttachmentPrefix` is taken to be base64, as
+// written by `EmbedAttachment`, and returned decoded under its full keyword.
+// All other values are returned verbatim.  An attachment which is not valid
+// base64 is an error.
+func ExtractAllDecoded(data []byte, opts ...Option) (map[string][]byte, error) {
+	m, err := ExtractAll(data, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range m {
+		if !strings.HasPrefix(k, AttachmentPrefix) {
+			continue
+		}
+		blob, err := base64.StdEncoding.DecodeString(string(v))
+		if err != nil {
+			return nil, fmt.Errorf("attachment (%s): %w", strings.TrimPrefix(k, AttachmentPrefix), err)
+		}
+		m[k] = blob
+	}
+	return m, nil
+}
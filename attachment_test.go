@@ -0,0 +1,68 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestAttachmentRoundTrip(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	blob := []byte{0, 1, 2, 0xfe, 0xff, 0, 42}
+	out, err := EmbedAttachment(bs, "sig", blob)
+	fatalIfError(t, err)
+	out, err = EmbedITXT(out, "Unrelated", "Value")
+	fatalIfError(t, err)
+
+	act, err := ExtractAttachment(out, "sig")
+	fatalIfError(t, err)
+	if !bytes.Equal(act, blob) {
+		t.Errorf("Expected %v, got %v\n", blob, act)
+	}
+
+	names, err := ListAttachments(out)
+	fatalIfError(t, err)
+	if len(names) != 1 || names[0] != "sig" {
+		t.Errorf("Expected [sig], got %v\n", names)
+	}
+
+	if _, err := ExtractAttachment(out, "missing"); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+	if _, err := EmbedAttachment(bs, "", blob); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
+
+func TestExtractAllDecoded(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	blob := []byte{0, 1, 2, 0xff}
+	out, err := EmbedAttachment(bs, "blob.bin", blob)
+	fatalIfError(t, err)
+	out, err = EmbedTEXT(out, "Plain", "aGVsbG8=")
+	fatalIfError(t, err)
+
+	m, err := ExtractAllDecoded(out)
+	fatalIfError(t, err)
+	if !bytes.Equal(m[AttachmentPrefix+"blob.bin"], blob) {
+		t.Errorf("Expected decoded attachment, got %v\n", m[AttachmentPrefix+"blob.bin"])
+	}
+	if string(m["Plain"]) != "aGVsbG8=" {
+		t.Errorf("Expected plain value verbatim, got %s\n", m["Plain"])
+	}
+
+	// Negative test cases.
+	out, err = EmbedITXT(out, AttachmentPrefix+"broken", "not base64!")
+	fatalIfError(t, err)
+	if _, err := ExtractAllDecoded(out); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
@@ -0,0 +1,427 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// maxChunkLength is the largest data length the png specification permits for
+// a single chunk.
+const maxChunkLength = 1<<31 - 1
+
+////////////////////////////////////////////////////////////////////////////////
+
+// chunk describes the location of a single chunk within a png byte stream.
+type chunk struct {
+	ct     string
+	offset int // Offset of the chunk's length field.
+	length int // Length of the chunk's data.
+}
+
+// end returns the offset of the first byte following the chunk's CRC.
+func (c chunk) end() int {
+	return c.offset + 12 + c.length
+}
+
+// data returns the chunk's data slice from the png byte stream `bs`.
+func (c chunk) data(bs []byte) []byte {
+	return bs[c.offset+8 : c.offset+8+c.length]
+}
+
+// storedCRC returns the CRC recorded for the chunk in `bs`.
+func (c chunk) storedCRC(bs []byte) uint32 {
+	return binary.BigEndian.Uint32(bs[c.end()-4 : c.end()])
+}
+
+// computedCRC returns the CRC computed over the chunk's type and data in `bs`.
+func (c chunk) computedCRC(bs []byte) uint32 {
+	return crc32.ChecksumIEEE(bs[c.offset+4 : c.end()-4])
+}
+
+// crcValid returns true if the CRC stored for the chunk in `bs` matches the
+// CRC computed over its type and data.
+func (c chunk) crcValid(bs []byte) bool {
+	return c.storedCRC(bs) == c.computedCRC(bs)
+}
+
+// scanChunks walks the chunks that follow the png magic number in `data` and
+// returns their locations in file order.  Scanning stops after IEND.
+func scanChunks(data []byte) ([]chunk, error) {
+	return scanChunksLimited(data, maxChunkLength, math.MaxInt)
+}
+
+// scanChunks is like the `scanChunks` function but aborts with
+// `ErrTooManyChunks` once more chunks than allowed by `WithMaxChunks` have been
+// seen.  The extractors scan with it, so the cap covers every extraction path.
+func (o *options) scanChunks(data []byte) ([]chunk, error) {
+	return scanChunksLimited(data, maxChunkLength, o.maxChunks)
+}
+
+// scanChunksLimited is like `scanChunks` but rejects any chunk whose declared
+// length exceeds `maxChunk` before looking at its data, and aborts once more
+// than `maxChunks` chunks have been seen.  When the data ends in the middle of
+// a chunk, the chunks preceding it are returned along with an error wrapping
+// `ErrChunkTruncated`.
+func scanChunksLimited(data []byte, maxChunk, maxChunks int) ([]chunk, error) {
+	return scanChunksUntil(data, maxChunk, maxChunks, "")
+}
+
+// scanChunksUntil is like `scanChunksLimited` but stops before the first chunk
+// of type `stop`, leaving it and everything after it unread.
+func scanChunksUntil(data []byte, maxChunk, maxChunks int, stop string) ([]chunk, error) {
+	if err := checkSignature(data); err != nil {
+		return nil, err
+	}
+
+	chunks := []chunk{}
+	for off := len(pngMagic); off < len(data); {
+		if len(data)-off < 12 {
+			return chunks, fmt.Errorf("%w: chunk at offset %d", ErrChunkTruncated, off)
+		}
+		c := chunk{
+			ct:     string(data[off+4 : off+8]),
+			offset: off,
+			length: int(binary.BigEndian.Uint32(data[off : off+4])),
+		}
+		if c.ct == stop {
+			break
+		}
+		if c.length > maxChunk {
+			return nil, fmt.Errorf("chunk %s at offset %d declares length %d, exceeding limit %d",
+				c.ct, off, c.length, maxChunk)
+		}
+		if c.length > len(data)-off-12 {
+			return chunks, fmt.Errorf("%w: chunk %s at offset %d", ErrChunkTruncated, c.ct, off)
+		}
+		if len(chunks) == maxChunks {
+			return nil, fmt.Errorf("%w: limit is %d", ErrTooManyChunks, maxChunks)
+		}
+		chunks = append(chunks, c)
+		if c.ct == "IEND" {
+			break
+		}
+		off = c.end()
+	}
+	return chunks, nil
+}
+
+// indexOfChunk returns the index of the first chunk of type `ct`, or -1.
+func indexOfChunk(chunks []chunk, ct string) int {
+	for i, c := range chunks {
+		if c.ct == ct {
+			return i
+		}
+	}
+	return -1
+}
+
+// trailingBytes returns the number of bytes following the IEND chunk, if the
+// scanned `chunks` end with one.
+func trailingBytes(data []byte, chunks []chunk) int {
+	if len(chunks) == 0 || chunks[len(chunks)-1].ct != "IEND" {
+		return 0
+	}
+	return len(data) - chunks[len(chunks)-1].end()
+}
+
+// TrimAfterIEND returns the PNG data with any bytes following the IEND chunk
+// removed, as left behind by concatenation or broken producers.
+func TrimAfterIEND(data []byte) ([]byte, error) {
+	chunks, err := scanChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	iend := indexOfChunk(chunks, "IEND")
+	if iend < 0 {
+		return nil, errors.New("missing IEND chunk")
+	}
+	return data[:chunks[iend].end()], nil
+}
+
+// TrailingBytes returns the number of bytes following the IEND chunk of the
+// PNG data, usually 0.  Any such bytes make embedding fail with
+// `ErrTrailingData`; use `TrimAfterIEND` to drop them.
+func TrailingBytes(data []byte) (int, error) {
+	chunks, err := scanChunks(data)
+	if err != nil {
+		return 0, err
+	}
+	if indexOfChunk(chunks, "IEND") < 0 {
+		return 0, errors.New("missing IEND chunk")
+	}
+	return trailingBytes(data, chunks), nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// isCriticalChunkType returns true if the chunk type is one which this library
+// must never inject on its own.
+func isCriticalChunkType(ct string) bool {
+	switch ct {
+	case "IHDR", "PLTE", "IDAT", "IEND":
+		return true
+	}
+	return false
+}
+
+// mustFollowPLTE returns true for ancillary chunks which, when a PLTE chunk is
+// present, must appear after it (and before the first IDAT).
+func mustFollowPLTE(ct string) bool {
+	switch ct {
+	case "tRNS", "bKGD", "hIST":
+		return true
+	}
+	return false
+}
+
+// insertionOffset returns the byte offset at which a chunk of type `ct` can be
+// injected such that the resulting file honors the chunk ordering constraints
+// of the png specification.  Chunks which cannot be legally placed are
+// rejected.
+func insertionOffset(chunks []chunk, ct string) (int, error) {
+	if isCriticalChunkType(ct) {
+		return 0, fmt.Errorf("cannot embed critical chunk (%s)", ct)
+	}
+
+	ihdr := indexOfChunk(chunks, "IHDR")
+	if ihdr != 0 {
+		return 0, ErrIHDRNotFirst
+	}
+	plte := indexOfChunk(chunks, "PLTE")
+	idat := indexOfChunk(chunks, "IDAT")
+
+	anchor := ihdr
+	if mustFollowPLTE(ct) {
+		if plte >= 0 {
+			anchor = plte
+		} else if ct == "hIST" {
+			return 0, errors.New("hIST requires a PLTE chunk")
+		}
+	}
+
+	if idat >= 0 && idat <= anchor {
+		return 0, fmt.Errorf("%s must appear before the first IDAT chunk", ct)
+	}
+	return chunks[anchor].end(), nil
+}
+
+// isTextChunkType returns true for the chunk types which carry a keyword and
+// text.
+func isTextChunkType(ct string) bool {
+	switch ct {
+	case "tEXt", "iTXt", "zTXt":
+		return true
+	}
+	return false
+}
+
+// placementOffset is like `insertionOffset` but honors the placement `p` for
+// text chunks: under `GroupWithExistingText` they are injected right before
+// the first existing text chunk, if there is one.  In animated pngs, grouping
+// never places a chunk past acTL, where it could land inside the frame
+// sequence.
+func placementOffset(chunks []chunk, ct string, p Placement) (int, error) {
+	off, err := insertionOffset(chunks, ct)
+	if err != nil || p != GroupWithExistingText || !isTextChunkType(ct) {
+		return off, err
+	}
+
+	for _, c := range chunks {
+		if c.ct == "acTL" {
+			break
+		}
+		if isTextChunkType(c.ct) {
+			return c.offset, nil
+		}
+	}
+	return off, nil
+}
+
+// moveIHDRFirst returns the png data with its IHDR chunk moved in front of any
+// chunks which precede it.  Data whose IHDR is already first is returned as
+// is.
+func moveIHDRFirst(data []byte) ([]byte, error) {
+	chunks, err := scanChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	ihdr := indexOfChunk(chunks, "IHDR")
+	if ihdr < 0 {
+		return nil, errors.New("missing IHDR chunk")
+	}
+	if ihdr == 0 {
+		return data, nil
+	}
+
+	ordered := append([]chunk{chunks[ihdr]}, chunks[:ihdr]...)
+	ordered = append(ordered, chunks[ihdr+1:]...)
+	return rewriteChunks(data, chunks, func(i int, c chunk) []byte {
+		return ordered[i].raw(data)
+	}), nil
+}
+
+// EmbedChunk injects an ancillary chunk of type `ct` carrying `data` into the
+// png image `img`.  The chunk is placed according to the ordering constraints
+// of its type: `tRNS`, `bKGD` and `hIST` follow the PLTE chunk when one is
+// present, all others follow IHDR.  Chunks that cannot be placed legally are
+// rejected with an error.
+func EmbedChunk(img []byte, ct string, data []byte) ([]byte, error) {
+	pngChunk, err := buildChunk(ct, data)
+	if err != nil {
+		return nil, err
+	}
+	return embed(img, pngChunk)
+}
+
+// raw returns the full encoded chunk, framing included, from `bs`.
+func (c chunk) raw(bs []byte) []byte {
+	return bs[c.offset:c.end()]
+}
+
+// rewriteChunks reassembles the png `data` chunk by chunk.  For every chunk,
+// `fn` returns the encoded bytes to write in its place: the chunk's own raw
+// bytes copy it through untouched, and nil drops it.  Any bytes following the
+// last chunk are copied through as-is.
+func rewriteChunks(data []byte, chunks []chunk, fn func(i int, c chunk) []byte) []byte {
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:len(pngMagic)]...)
+
+	end := len(pngMagic)
+	for i, c := range chunks {
+		out = append(out, fn(i, c)...)
+		end = c.end()
+	}
+	return append(out, data[end:]...)
+}
+
+// keyword returns the keyword of a text chunk in `bs`, which for `tEXt`,
+// `iTXt` and `zTXt` alike is the null-terminated field its data starts with.
+// False is returned for any other chunk, or one without a terminated keyword.
+func (c chunk) keyword(bs []byte) (string, bool) {
+	if !isTextChunkType(c.ct) {
+		return "", false
+	}
+
+	d := c.data(bs)
+	pt := bytes.IndexByte(d, NULL_SEPERATOR)
+	if pt < 0 {
+		return "", false
+	}
+	return string(d[:pt]), true
+}
+
+// stripText drops every text chunk whose keyword satisfies `drop`, copying all
+// other chunks through untouched.
+func stripText(data []byte, drop func(ct, keyword string) bool) ([]byte, error) {
+	chunks, err := scanChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return rewriteChunks(data, chunks, func(i int, c chunk) []byte {
+		if k, ok := c.keyword(data); ok && drop(c.ct, k) {
+			return nil
+		}
+		return c.raw(data)
+	}), nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ChunkInfo describes a chunk found in a png byte stream.
+type ChunkInfo struct {
+	Type   string
+	Offset int // Offset of the chunk's length field.
+	Length int // Length of the chunk's data.
+}
+
+// ListChunks returns the type, offset and data length of the chunks in the
+// PNG data, in file order.  Like `pngr.ReaderOptions.IncludedChunkTypes`, a
+// non-empty `includedChunkTypes` restricts the listing to those types; by
+// default every chunk is listed.
+func ListChunks(data []byte, includedChunkTypes ...string) ([]ChunkInfo, error) {
+	chunks, err := scanChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]ChunkInfo, 0, len(chunks))
+	for _, c := range chunks {
+		if len(includedChunkTypes) > 0 && !containsString(includedChunkTypes, c.ct) {
+			continue
+		}
+		ret = append(ret, ChunkInfo{Type: c.ct, Offset: c.offset, Length: c.length})
+	}
+	return ret, nil
+}
+
+// ChunkRange is the byte span of a chunk in a png byte stream, from its length
+// field through its CRC, so `data[Start:End]` is the whole chunk.
+type ChunkRange struct {
+	Type       string
+	Start, End int
+}
+
+// ChunkRanges returns the byte span of every chunk in the PNG data, in file
+// order, so callers can slice out chunks without copying them.
+func ChunkRanges(data []byte) ([]ChunkRange, error) {
+	chunks, err := scanChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]ChunkRange, 0, len(chunks))
+	for _, c := range chunks {
+		ret = append(ret, ChunkRange{Type: c.ct, Start: c.offset, End: c.end()})
+	}
+	return ret, nil
+}
+
+// containsString returns true if `s` is one of `ss`.
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// StripAllText removes every `tEXt`, `iTXt` and `zTXt` chunk from the PNG
+// data.  All other chunks are copied through untouched.
+func StripAllText(data []byte) ([]byte, error) {
+	return stripText(data, func(ct, k string) bool { return true })
+}
+
+// DeduplicateChunks removes ancillary chunks which are byte for byte identical
+// to an earlier chunk in the PNG data, keeping the first occurrence.  Critical
+// chunks, IDAT included, are always kept.
+func DeduplicateChunks(data []byte) ([]byte, error) {
+	chunks, err := scanChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	return rewriteChunks(data, chunks, func(i int, c chunk) []byte {
+		raw := c.raw(data)
+		if isCriticalChunkType(c.ct) {
+			return raw
+		}
+		if seen[string(raw)] {
+			return nil
+		}
+		seen[string(raw)] = true
+		return raw
+	}), nil
+}
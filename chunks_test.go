@@ -0,0 +1,390 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// palettePNG returns an encoded 4x4 paletted png image.
+func palettePNG(t *testing.T) []byte {
+	img := image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{
+		color.RGBA{255, 0, 0, 255},
+		color.RGBA{0, 0, 255, 255},
+	})
+	img.SetColorIndex(1, 1, 1)
+
+	buf := &bytes.Buffer{}
+	fatalIfError(t, png.Encode(buf, img))
+	return buf.Bytes()
+}
+
+// chunkTypes returns the chunk types found in `data`, in file order.
+func chunkTypes(t *testing.T, data []byte) []string {
+	chunks, err := scanChunks(data)
+	fatalIfError(t, err)
+
+	cts := []string{}
+	for _, c := range chunks {
+		cts = append(cts, c.ct)
+	}
+	return cts
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestScanChunks(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	cts := chunkTypes(t, bs)
+	if len(cts) != 3 || cts[0] != "IHDR" || cts[1] != "IDAT" || cts[2] != "IEND" {
+		t.Errorf("Unexpected chunk types %v\n", cts)
+	}
+
+	for _, data := range [][]byte{
+		{1, 2, 3},
+		bs[:len(bs)-3],
+	} {
+		if _, err := scanChunks(data); err == nil {
+			t.Errorf("Expected error, got nil!\n")
+		}
+	}
+}
+
+func TestEmbedChunkPlacement(t *testing.T) {
+	pal := palettePNG(t)
+	red, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	for _, tc := range []struct {
+		data  []byte
+		ct    string
+		after string
+		isErr bool
+	}{
+		// Negative test cases.
+		{data: pal, ct: "IDAT", isErr: true},
+		{data: red, ct: "hIST", isErr: true},
+		{data: []byte{1, 2, 3}, ct: "tRNS", isErr: true},
+
+		// Positive test cases.
+		{data: pal, ct: "tRNS", after: "PLTE", isErr: false},
+		{data: pal, ct: "bKGD", after: "PLTE", isErr: false},
+		{data: pal, ct: "hIST", after: "PLTE", isErr: false},
+		{data: pal, ct: "gAMA", after: "IHDR", isErr: false},
+		{data: red, ct: "bKGD", after: "IHDR", isErr: false},
+	} {
+		out, err := EmbedChunk(tc.data, tc.ct, []byte{0, 0})
+		if tc.isErr == false {
+			fatalIfError(t, err)
+		} else {
+			if err == nil {
+				t.Errorf("Expected error for %s, got nil!\n", tc.ct)
+			}
+			continue
+		}
+
+		cts := chunkTypes(t, out)
+		i := 0
+		for ; i < len(cts) && cts[i] != tc.ct; i++ {
+		}
+		if i == 0 || i == len(cts) || cts[i-1] != tc.after {
+			t.Errorf("Expected %s after %s, got %v\n", tc.ct, tc.after, cts)
+		}
+	}
+}
+
+func TestEmbedAnchorsByType(t *testing.T) {
+	pal := palettePNG(t)
+	red, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	for _, data := range [][]byte{pal, red} {
+		out, err := EmbedTEXT(data, "Key", "Value")
+		fatalIfError(t, err)
+
+		cts := chunkTypes(t, out)
+		if cts[0] != "IHDR" || cts[1] != "tEXt" {
+			t.Errorf("Expected tEXt right after IHDR, got %v\n", cts)
+		}
+
+		out, err = EmbedChunk(out, "tRNS", []byte{0})
+		fatalIfError(t, err)
+
+		cts = chunkTypes(t, out)
+		i := indexOf(cts, "tRNS")
+		if plte := indexOf(cts, "PLTE"); plte >= 0 && plte > i {
+			t.Errorf("Expected tRNS after PLTE, got %v\n", cts)
+		}
+		if i > indexOf(cts, "IDAT") {
+			t.Errorf("Expected tRNS before IDAT, got %v\n", cts)
+		}
+	}
+
+	// Move IDAT ahead of IHDR; the anchor is no longer the first chunk.
+	chunks, err := scanChunks(red)
+	fatalIfError(t, err)
+	bad := append([]byte{}, red[:8]...)
+	bad = append(bad, chunks[1].raw(red)...)
+	bad = append(bad, chunks[0].raw(red)...)
+	bad = append(bad, chunks[2].raw(red)...)
+	if _, err := EmbedTEXT(bad, "Key", "Value"); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
+
+// indexOf returns the index of `s` in `ss`, or -1.
+func indexOf(ss []string, s string) int {
+	for i, v := range ss {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestListChunksAndStrip(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXT(bs, "Key0", "Value0")
+	fatalIfError(t, err)
+	out, err = EmbedITXT(out, "Key1", "Value1")
+	fatalIfError(t, err)
+
+	cis, err := ListChunks(out)
+	fatalIfError(t, err)
+	if len(cis) != 5 || cis[1].Type != "iTXt" || cis[2].Type != "tEXt" {
+		t.Errorf("Unexpected chunks %v\n", cis)
+	}
+	if cis[2].Length != len("Key0")+1+len("Value0") {
+		t.Errorf("Unexpected tEXt length %d\n", cis[2].Length)
+	}
+	if cis[1].Offset != cis[0].Offset+12+cis[0].Length {
+		t.Errorf("Unexpected iTXt offset %d\n", cis[1].Offset)
+	}
+
+	stripped, err := StripAllText(out)
+	fatalIfError(t, err)
+	if !bytes.Equal(stripped, bs) {
+		t.Errorf("Expected stripping to restore the original file\n")
+	}
+}
+
+func TestTrimAfterIEND(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+	junk := append(append([]byte{}, bs...), make([]byte, 10)...)
+
+	if _, err := EmbedTEXT(junk, "Key", "Value"); !errors.Is(err, ErrTrailingData) {
+		t.Errorf("Expected ErrTrailingData, got %v\n", err)
+	}
+
+	out, err := TrimAfterIEND(junk)
+	fatalIfError(t, err)
+	if !bytes.Equal(out, bs) {
+		t.Errorf("Expected %d bytes after trimming, got %d\n", len(bs), len(out))
+	}
+	_, err = EmbedTEXT(out, "Key", "Value")
+	fatalIfError(t, err)
+
+	if _, err := TrimAfterIEND(bs[:len(bs)-12]); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
+
+func TestTrailingBytes(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	// Positive test cases.
+	for _, n := range []int{0, 1, 10} {
+		junk := append(append([]byte{}, bs...), make([]byte, n)...)
+		act, err := TrailingBytes(junk)
+		fatalIfError(t, err)
+		if act != n {
+			t.Errorf("Expected %d trailing bytes, got %d\n", n, act)
+		}
+
+		trimmed, err := TrimAfterIEND(junk)
+		fatalIfError(t, err)
+		if act, _ := TrailingBytes(trimmed); act != 0 {
+			t.Errorf("Expected no trailing bytes after trimming, got %d\n", act)
+		}
+	}
+
+	// Negative test cases.
+	if _, err := TrailingBytes(bs[:len(bs)-12]); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
+
+func TestListChunksFiltered(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXT(bs, "Key0", "Value0")
+	fatalIfError(t, err)
+	out, err = EmbedITXT(out, "Key1", "Value1")
+	fatalIfError(t, err)
+
+	cis, err := ListChunks(out, "tEXt", "iTXt", "zTXt")
+	fatalIfError(t, err)
+	if len(cis) != 2 {
+		t.Errorf("Expected 2 text chunks, got %v\n", cis)
+	}
+	for _, ci := range cis {
+		if isCriticalChunkType(ci.Type) {
+			t.Errorf("Expected critical chunks to be excluded, got %s\n", ci.Type)
+		}
+	}
+
+	cis, err = ListChunks(out, "IDAT")
+	fatalIfError(t, err)
+	if len(cis) != 1 || cis[0].Type != "IDAT" {
+		t.Errorf("Expected a single IDAT chunk, got %v\n", cis)
+	}
+}
+
+func TestGroupWithExistingText(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXT(bs, "Old", "o")
+	fatalIfError(t, err)
+	out, err = EmbedChunk(out, "gAMA", []byte{0, 0, 0xb1, 0x8f})
+	fatalIfError(t, err)
+
+	for _, tc := range []struct {
+		opts []Option
+		exp  []string
+	}{
+		{exp: []string{"IHDR", "tEXt", "gAMA", "tEXt", "IDAT", "IEND"}},
+		{
+			opts: []Option{WithPlacement(GroupWithExistingText)},
+			exp:  []string{"IHDR", "gAMA", "tEXt", "tEXt", "IDAT", "IEND"},
+		},
+	} {
+		grouped, err := EmbedTEXT(out, "New", "n", tc.opts...)
+		fatalIfError(t, err)
+		if cts := chunkTypes(t, grouped); strings.Join(cts, ",") != strings.Join(tc.exp, ",") {
+			t.Errorf("Expected %v, got %v\n", tc.exp, cts)
+		}
+	}
+
+	// Without existing text, grouping falls back to following IHDR.
+	grouped, err := EmbedTEXT(bs, "New", "n", WithPlacement(GroupWithExistingText))
+	fatalIfError(t, err)
+	if cts := chunkTypes(t, grouped); cts[1] != "tEXt" {
+		t.Errorf("Expected tEXt after IHDR, got %v\n", cts)
+	}
+}
+
+func TestDeduplicateChunks(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	gama := []byte{0, 0, 0xb1, 0x8f}
+	out, err := EmbedChunk(bs, "gAMA", gama)
+	fatalIfError(t, err)
+	out, err = EmbedTEXT(out, "Key", "Value")
+	fatalIfError(t, err)
+	out, err = EmbedChunk(out, "gAMA", gama)
+	fatalIfError(t, err)
+	out, err = EmbedChunk(out, "pHYs", []byte{0, 0, 0x0b, 0x13, 0, 0, 0x0b, 0x13, 1})
+	fatalIfError(t, err)
+
+	// A second IDAT identical to the first is critical, and must stay.
+	chunks, err := scanChunks(out)
+	fatalIfError(t, err)
+	idat := chunks[indexOfChunk(chunks, "IDAT")]
+	iend := chunks[indexOfChunk(chunks, "IEND")]
+	out = append(append(append([]byte{}, out[:iend.offset]...), idat.raw(out)...), iend.raw(out)...)
+
+	dedup, err := DeduplicateChunks(out)
+	fatalIfError(t, err)
+
+	cts := chunkTypes(t, dedup)
+	exp := "IHDR,pHYs,gAMA,tEXt,IDAT,IDAT,IEND"
+	if strings.Join(cts, ",") != exp {
+		t.Errorf("Expected %s, got %v\n", exp, cts)
+	}
+}
+
+func TestIHDRNotFirst(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	// Move a gAMA chunk in front of IHDR.
+	withGAMA, err := EmbedChunk(bs, "gAMA", []byte{0, 0, 0xb1, 0x8f})
+	fatalIfError(t, err)
+	chunks, err := scanChunks(withGAMA)
+	fatalIfError(t, err)
+	bad := append([]byte{}, withGAMA[:8]...)
+	bad = append(bad, chunks[1].raw(withGAMA)...)
+	bad = append(bad, chunks[0].raw(withGAMA)...)
+	bad = append(bad, withGAMA[chunks[2].offset:]...)
+
+	if _, err := EmbedTEXT(bad, "Key", "Value"); !errors.Is(err, ErrIHDRNotFirst) {
+		t.Errorf("Expected ErrIHDRNotFirst, got %v\n", err)
+	}
+	if _, err := GetHeader(bad); !errors.Is(err, ErrIHDRNotFirst) {
+		t.Errorf("Expected ErrIHDRNotFirst, got %v\n", err)
+	}
+
+	out, err := EmbedTEXT(bad, "Key", "Value", WithIHDRRepair())
+	fatalIfError(t, err)
+	exp := "IHDR,tEXt,gAMA,IDAT,IEND"
+	if cts := chunkTypes(t, out); strings.Join(cts, ",") != exp {
+		t.Errorf("Expected %s, got %v\n", exp, cts)
+	}
+	if _, err := png.DecodeConfig(bytes.NewReader(out)); err != nil {
+		t.Errorf("Expected a decodable png, got %v\n", err)
+	}
+}
+
+func TestChunkRanges(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXT(bs, "Key0", "Value0")
+	fatalIfError(t, err)
+	out, err = EmbedZTXT(out, "Key1", strings.Repeat("Value1", 100))
+	fatalIfError(t, err)
+
+	crs, err := ChunkRanges(out)
+	fatalIfError(t, err)
+	if len(crs) != 5 || crs[0].Type != "IHDR" || crs[4].Type != "IEND" {
+		t.Errorf("Unexpected ranges %v\n", crs)
+	}
+
+	// The signature and the ranges, concatenated, are the whole file.
+	rebuilt := append([]byte{}, out[:len(pngMagic)]...)
+	for i, cr := range crs {
+		if i > 0 && cr.Start != crs[i-1].End {
+			t.Errorf("Expected range %d to start at %d, got %d\n", i, crs[i-1].End, cr.Start)
+		}
+		if string(out[cr.Start+4:cr.Start+8]) != cr.Type {
+			t.Errorf("Expected a %s chunk at %d\n", cr.Type, cr.Start)
+		}
+		rebuilt = append(rebuilt, out[cr.Start:cr.End]...)
+	}
+	if !bytes.Equal(rebuilt, out) {
+		t.Errorf("Expected the ranges to reconstruct the file\n")
+	}
+
+	// Negative test cases.
+	if _, err := ChunkRanges(out[:20]); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
@@ -0,0 +1,161 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// deflate compresses `data` into a zlib stream as used by zTXt and compressed
+// iTXt chunks.
+func deflate(data []byte) []byte {
+	buf := &bytes.Buffer{}
+	zw := zlib.NewWriter(buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// largeTextSize is the serialized size from which `RecommendChunkType`
+// considers compressing a value.
+const largeTextSize = 1024
+
+// compressLarge returns `val` deflated, and true, if it is at least
+// `largeTextSize` bytes long and compression makes it smaller.
+func compressLarge(val []byte) ([]byte, bool) {
+	if len(val) < largeTextSize {
+		return nil, false
+	}
+	z := deflate(val)
+	return z, len(z)+1 < len(val)
+}
+
+func formatZTXTChunk(compressed []byte, keyword string) []byte {
+
+	// +----------+----------------+--------------------+-----------------+
+	// | Keyword  | Null separator | Compression method | Compressed text |
+	// +----------+----------------+--------------------+-----------------+
+	// | 1–79     | 1 byte         | 1 byte             | n bytes         |
+	// | bytes    |                |                    |                 |
+	// +----------+----------------+--------------------+-----------------+
+
+	zTXtChunk := append([]byte(keyword), NULL_SEPERATOR, 0)
+	zTXtChunk = append(zTXtChunk, compressed...)
+	return zTXtChunk
+
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// EmbedZTXT is like `EmbedTEXT` but deflates the value into a `zTXt` chunk.
+// When compression would not make the value smaller, as for tiny or
+// incompressible values, a plain `tEXt` chunk is written instead.
+func EmbedZTXT(data []byte, k string, v interface{}, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	k, err := o.keyword(k, false)
+	if err != nil {
+		return nil, err
+	}
+	data = o.stripBOM(data)
+
+	val, err := o.serializeTEXT(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var pngChunk []byte
+	if z := deflate(val); len(z)+1 < len(val) {
+		pngChunk, _ = buildChunk(`zTXt`, formatZTXTChunk(z, k))
+	} else {
+		pngChunk, _ = buildChunk(`tEXt`, formatTEXTChunk(val, k))
+	}
+	return embedWithOptions(data, pngChunk, k, v, o)
+}
+
+// EmbedITXTCompressed is like `EmbedITXT` but deflates the value and sets the
+// iTXt compression flag.  When compression would not make the value smaller,
+// the value is stored uncompressed with the compression flag cleared.
+func EmbedITXTCompressed(data []byte, k string, v interface{}, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	k, err := o.keyword(k, true)
+	if err != nil {
+		return nil, err
+	}
+	data = o.stripBOM(data)
+
+	val, err := o.serialize(v)
+	if err != nil {
+		return nil, err
+	}
+
+	compressionFlag := CompressionFlagOff
+	if z := deflate(val); len(z) < len(val) {
+		val, compressionFlag = z, CompressionFlagOn
+	}
+	pngChunk, _ := buildChunk(`iTXt`, formatITXTChunk(val, k, compressionFlag, CompressionZlib, "", ""))
+	return embedWithOptions(data, pngChunk, k, v, o)
+}
+
+// RecommendChunkType serializes `v` as the embedders do and returns the text
+// chunk type best suited to it: "zTXt" for values of at least 1 KiB that
+// compress and are representable in Latin-1, "tEXt" for other Latin-1 values,
+// and "iTXt" for UTF-8 text outside Latin-1.  Non-ASCII Latin-1 text should be
+// embedded with `WithLatin1Transcode`.  Values which are not valid UTF-8 are
+// rejected.
+func RecommendChunkType(v interface{}) (string, error) {
+	val, err := newOptions(nil).serialize(v)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(val) {
+		return "", errors.New("value is not valid UTF-8 text")
+	}
+	if _, err := toLatin1(val); err != nil {
+		return "iTXt", nil
+	}
+	if _, ok := compressLarge(val); ok {
+		return "zTXt", nil
+	}
+	return "tEXt", nil
+}
+
+// ChunkCompressionInfo returns the compression flag and method declared by the
+// first chunk of type `chunkType` with keyword `keyword`, without decoding its
+// text.  For `iTXt` both come from the chunk.  `zTXt` chunks carry only a
+// method and are always compressed, so their flag is reported as 1.  Any other
+// chunk type is rejected.
+func ChunkCompressionInfo(data []byte, chunkType, keyword string) (flag, method int, err error) {
+	if chunkType != "iTXt" && chunkType != "zTXt" {
+		return 0, 0, fmt.Errorf("chunk type (%s) carries no compression info", chunkType)
+	}
+
+	chunks, err := newOptions(nil).scanChunks(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, c := range chunks {
+		if k, ok := c.keyword(data); !ok || c.ct != chunkType || k != keyword {
+			continue
+		}
+
+		// Both fields directly follow the keyword's null separator.
+		d := c.data(data)[len(keyword)+1:]
+		if chunkType == "zTXt" {
+			if len(d) < 1 {
+				return 0, 0, errors.New("zTXt chunk too short for compression method")
+			}
+			return 1, int(d[0]), nil
+		}
+		if len(d) < 2 {
+			return 0, 0, errITXTTooShort("compression method")
+		}
+		return int(d[0]), int(d[1]), nil
+	}
+	return 0, 0, fmt.Errorf("%s chunk with keyword (%s) not found", chunkType, keyword)
+}
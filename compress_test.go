@@ -0,0 +1,137 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestCompressOnlyWhenSmaller(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	noise := make([]byte, 64)
+	_, err = rand.Read(noise)
+	fatalIfError(t, err)
+
+	compressible := strings.Repeat("metadata ", 100)
+	for _, tc := range []struct {
+		v          string
+		compressed bool
+	}{
+		{v: "x", compressed: false},
+		{v: hex.EncodeToString(noise)[:32], compressed: false},
+		{v: compressible, compressed: true},
+	} {
+		out, err := EmbedZTXT(bs, "Key", tc.v)
+		fatalIfError(t, err)
+
+		exp := "tEXt"
+		if tc.compressed {
+			exp = "zTXt"
+		}
+		if cts := chunkTypes(t, out); cts[1] != exp {
+			t.Errorf("Expected %s for %d byte value, got %v\n", exp, len(tc.v), cts)
+		}
+		v, found, err := GetValue(out, "Key")
+		fatalIfError(t, err)
+		if !found || string(v) != tc.v {
+			t.Errorf("Expected %s, got %s\n", tc.v, v)
+		}
+
+		out, err = EmbedITXTCompressed(bs, "Key", tc.v)
+		fatalIfError(t, err)
+
+		chunks, err := scanChunks(out)
+		fatalIfError(t, err)
+		rec, err := parseITXTRecord(chunks[1].data(out))
+		fatalIfError(t, err)
+		if (rec.compressionFlag == 1) != tc.compressed {
+			t.Errorf("Unexpected compression flag %d for %d byte value\n",
+				rec.compressionFlag, len(tc.v))
+		}
+		v, found, err = GetValue(out, "Key")
+		fatalIfError(t, err)
+		if !found || string(v) != tc.v {
+			t.Errorf("Expected %s, got %s\n", tc.v, v)
+		}
+	}
+}
+
+func TestRecommendChunkType(t *testing.T) {
+	for _, tc := range []struct {
+		v     interface{}
+		exp   string
+		isErr bool
+	}{
+		// Negative test cases.
+		{v: string([]byte{0xff, 0xfe}), isErr: true},
+
+		// Positive test cases.
+		{v: "short", exp: "tEXt"},
+		{v: "café", exp: "tEXt"},
+		{v: 42, exp: "tEXt"},
+		{v: "日本語", exp: "iTXt"},
+		{v: strings.Repeat("日本語", 1000), exp: "iTXt"},
+		{v: strings.Repeat("metadata ", 200), exp: "zTXt"},
+		{v: strings.Repeat("metadata ", 100), exp: "tEXt"},
+	} {
+		ct, err := RecommendChunkType(tc.v)
+		if tc.isErr {
+			if err == nil {
+				t.Errorf("Expected error for %v, got nil!\n", tc.v)
+			}
+			continue
+		}
+		fatalIfError(t, err)
+		if ct != tc.exp {
+			t.Errorf("Expected %s, got %s\n", tc.exp, ct)
+		}
+	}
+}
+
+func TestChunkCompressionInfo(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	long := strings.Repeat("metadata ", 100)
+	out, err := EmbedITXTCompressed(bs, "Packed", long)
+	fatalIfError(t, err)
+	out, err = EmbedITXT(out, "Plain", "short")
+	fatalIfError(t, err)
+	out, err = EmbedZTXT(out, "Zipped", long)
+	fatalIfError(t, err)
+
+	for _, tc := range []struct {
+		ct, k        string
+		flag, method int
+		isErr        bool
+	}{
+		// Negative test cases.
+		{ct: "tEXt", k: "Plain", isErr: true},
+		{ct: "iTXt", k: "Zipped", isErr: true},
+
+		// Positive test cases.
+		{ct: "iTXt", k: "Packed", flag: 1, method: 0},
+		{ct: "iTXt", k: "Plain", flag: 0, method: 0},
+		{ct: "zTXt", k: "Zipped", flag: 1, method: 0},
+	} {
+		flag, method, err := ChunkCompressionInfo(out, tc.ct, tc.k)
+		if tc.isErr {
+			if err == nil {
+				t.Errorf("Expected error for %s %s, got nil!\n", tc.ct, tc.k)
+			}
+			continue
+		}
+		fatalIfError(t, err)
+		if flag != tc.flag || method != tc.method {
+			t.Errorf("Expected %d/%d for %s, got %d/%d\n", tc.flag, tc.method, tc.k, flag, method)
+		}
+	}
+}
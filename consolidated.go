@@ -0,0 +1,57 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// MetaKey is the keyword of the chunk `EmbedConsolidated` stores its JSON
+// document under.
+const MetaKey = "pngembed:meta"
+
+////////////////////////////////////////////////////////////////////////////////
+
+// EmbedConsolidated serializes the whole of `kv` into a single JSON object and
+// stores it in one chunk keyed `pngembed:meta`: a `zTXt` chunk when that is
+// smaller, a `tEXt` chunk otherwise.  Any existing consolidated chunk is
+// replaced.  Prefer this over `EmbedMulti` when embedding many keys, as it
+// spends the chunk framing and keyword only once and lets the keys compress
+// together.  Prefer `EmbedMulti` when other tools must read the keys
+// individually.
+func EmbedConsolidated(data []byte, kv map[string]interface{}, opts ...Option) ([]byte, error) {
+	val, err := marshalJSON(kv)
+	if err != nil {
+		return nil, err
+	}
+
+	o := newOptions(opts)
+	data, err = stripText(o.stripBOM(data), func(ct, k string) bool {
+		return k == MetaKey
+	})
+	if err != nil {
+		return nil, err
+	}
+	return EmbedZTXT(data, MetaKey, string(val), opts...)
+}
+
+// ExtractConsolidated returns the map stored by `EmbedConsolidated`, with each
+// value left as raw JSON for the caller to decode into its own type.
+func ExtractConsolidated(data []byte) (map[string]json.RawMessage, error) {
+	v, found, err := GetValue(data, MetaKey)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("keyword (%s) not found", MetaKey)
+	}
+
+	ret := map[string]json.RawMessage{}
+	if err := json.Unmarshal(v, &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
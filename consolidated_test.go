@@ -0,0 +1,54 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestEmbedConsolidated(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	if _, err := ExtractConsolidated(bs); err == nil {
+		t.Errorf("Expected error for missing metadata, got nil!\n")
+	}
+
+	kv := map[string]interface{}{}
+	for i := 0; i < 20; i++ {
+		kv[fmt.Sprintf("key%02d", i)] = i
+	}
+	kv["key00"] = "a < b"
+
+	out, err := EmbedConsolidated(bs, kv)
+	fatalIfError(t, err)
+	out, err = EmbedConsolidated(out, kv)
+	fatalIfError(t, err)
+
+	if cis, _ := ListChunks(out, "tEXt", "zTXt"); len(cis) != 1 {
+		t.Errorf("Expected a single consolidated chunk, got %v\n", cis)
+	}
+
+	m, err := ExtractConsolidated(out)
+	fatalIfError(t, err)
+	if len(m) != 20 {
+		t.Errorf("Expected 20 keys, got %d\n", len(m))
+	}
+	var s string
+	fatalIfError(t, json.Unmarshal(m["key00"], &s))
+	if s != "a < b" {
+		t.Errorf("Expected a < b, got %s\n", s)
+	}
+	for i := 1; i < 20; i++ {
+		var n int
+		fatalIfError(t, json.Unmarshal(m[fmt.Sprintf("key%02d", i)], &n))
+		if n != i {
+			t.Errorf("Expected %d, got %d\n", i, n)
+		}
+	}
+}
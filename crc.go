@@ -0,0 +1,79 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// CRCResult reports the CRC check of a single chunk.
+type CRCResult struct {
+	Type        string
+	Offset      int // Offset of the chunk's length field.
+	StoredCRC   uint32
+	ComputedCRC uint32
+	Valid       bool
+
+	// Err is nil for a valid chunk, `ErrCRCMismatch` for a complete chunk
+	// whose CRC was not computed as the png specification requires, and
+	// `ErrChunkTruncated` for a chunk cut short by the end of the data.
+	Err error
+}
+
+// VerifyAllCRCs recomputes the IEEE CRC of every chunk in the PNG data and
+// reports each one against the CRC stored in the file, in file order.  A
+// corrupt chunk does not stop the scan; check `Valid` or `Err` on each
+// result.  If the data ends in the middle of a chunk, the last result reports
+// it with `ErrChunkTruncated` rather than as a CRC mismatch, so a cut-short
+// file can be told apart from one with wrong CRCs.
+func VerifyAllCRCs(data []byte) ([]CRCResult, error) {
+	chunks, err := scanChunks(data)
+	if err != nil && !errors.Is(err, ErrChunkTruncated) {
+		return nil, err
+	}
+
+	ret := make([]CRCResult, 0, len(chunks)+1)
+	for _, c := range chunks {
+		r := CRCResult{
+			Type:        c.ct,
+			Offset:      c.offset,
+			StoredCRC:   c.storedCRC(data),
+			ComputedCRC: c.computedCRC(data),
+		}
+		if r.Valid = r.StoredCRC == r.ComputedCRC; !r.Valid {
+			r.Err = ErrCRCMismatch
+		}
+		ret = append(ret, r)
+	}
+
+	if err != nil {
+		r := CRCResult{Offset: len(pngMagic), Err: ErrChunkTruncated}
+		if len(chunks) > 0 {
+			r.Offset = chunks[len(chunks)-1].end()
+		}
+		if r.Offset+8 <= len(data) {
+			r.Type = string(data[r.Offset+4 : r.Offset+8])
+		}
+		ret = append(ret, r)
+	}
+	return ret, nil
+}
+
+// FixCRCs returns a copy of the PNG data with the CRC of every chunk
+// recomputed from its type and data.  Chunk order and data are unchanged.
+// This repairs files whose chunk data was modified without updating CRCs.
+func FixCRCs(data []byte) ([]byte, error) {
+	chunks, err := scanChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	out := append([]byte{}, data...)
+	for _, c := range chunks {
+		binary.BigEndian.PutUint32(out[c.end()-4:c.end()], c.computedCRC(out))
+	}
+	return out, nil
+}
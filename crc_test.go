@@ -0,0 +1,125 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io/ioutil"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestVerifyAllCRCs(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXT(bs, "Key0", "Value0")
+	fatalIfError(t, err)
+	out, err = EmbedTEXT(out, "Key1", "Value1")
+	fatalIfError(t, err)
+
+	rs, err := VerifyAllCRCs(out)
+	fatalIfError(t, err)
+	for _, r := range rs {
+		if !r.Valid {
+			t.Errorf("Expected valid CRC for %s at %d\n", r.Type, r.Offset)
+		}
+	}
+
+	// Corrupt the last byte of the value of the second chunk (Key0).
+	chunks, err := scanChunks(out)
+	fatalIfError(t, err)
+	out[chunks[2].end()-5] ^= 0xff
+
+	rs, err = VerifyAllCRCs(out)
+	fatalIfError(t, err)
+	if len(rs) != 5 {
+		t.Fatalf("Expected 5 results, got %d\n", len(rs))
+	}
+	for i, r := range rs {
+		if r.Valid != (i != 2) {
+			t.Errorf("Unexpected validity %t for %s at %d\n", r.Valid, r.Type, r.Offset)
+		}
+		if r.Valid != (r.StoredCRC == r.ComputedCRC) {
+			t.Errorf("Valid flag disagrees with CRCs for %s\n", r.Type)
+		}
+	}
+}
+
+func TestFixCRCs(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	orig, err := EmbedTEXT(bs, "Key", "Value")
+	fatalIfError(t, err)
+
+	// Corrupt the CRCs of IHDR, tEXt and IEND.
+	bad := append([]byte{}, orig...)
+	chunks, err := scanChunks(bad)
+	fatalIfError(t, err)
+	for _, i := range []int{0, 1, 3} {
+		bad[chunks[i].end()-1] ^= 0xff
+	}
+
+	fixed, err := FixCRCs(bad)
+	fatalIfError(t, err)
+
+	rs, err := VerifyAllCRCs(fixed)
+	fatalIfError(t, err)
+	for _, r := range rs {
+		if !r.Valid {
+			t.Errorf("Expected valid CRC for %s after fixing\n", r.Type)
+		}
+	}
+	if !bytes.Equal(fixed, orig) {
+		t.Errorf("Expected fixed file to match the original\n")
+	}
+}
+
+func TestVerifyAllCRCsMismatchVsTruncated(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXT(bs, "Key", "Value")
+	fatalIfError(t, err)
+
+	// Recompute the tEXt CRC with the Castagnoli polynomial instead of IEEE.
+	bad := append([]byte{}, out...)
+	chunks, err := scanChunks(bad)
+	fatalIfError(t, err)
+	c := chunks[1]
+	binary.BigEndian.PutUint32(bad[c.end()-4:],
+		crc32.Checksum(bad[c.offset+4:c.end()-4], crc32.MakeTable(crc32.Castagnoli)))
+
+	rs, err := VerifyAllCRCs(bad)
+	fatalIfError(t, err)
+	for i, r := range rs {
+		exp := error(nil)
+		if i == 1 {
+			exp = ErrCRCMismatch
+		}
+		if r.Err != exp {
+			t.Errorf("Expected %v for %s, got %v\n", exp, r.Type, r.Err)
+		}
+	}
+
+	// Cut the file short in the middle of the IDAT chunk.
+	rs, err = VerifyAllCRCs(out[:chunks[2].end()-6])
+	fatalIfError(t, err)
+	if len(rs) != 3 {
+		t.Fatalf("Expected 3 results, got %d\n", len(rs))
+	}
+	last := rs[2]
+	if !errors.Is(last.Err, ErrChunkTruncated) || last.Type != "IDAT" || last.Offset != chunks[2].offset {
+		t.Errorf("Expected a truncated IDAT, got %+v\n", last)
+	}
+	for _, r := range rs[:2] {
+		if r.Err != nil {
+			t.Errorf("Expected valid %s, got %v\n", r.Type, r.Err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// parseDataURI splits a `data:image/png;base64,...` URI into its header, the
+// part between `data:` and the comma, and its decoded png data.
+func parseDataURI(uri string) (string, []byte, error) {
+	if !strings.HasPrefix(uri, "data:") {
+		return "", nil, errors.New("not a data URI")
+	}
+	header, payload, ok := strings.Cut(uri[len("data:"):], ",")
+	if !ok {
+		return "", nil, errors.New("data URI is missing its comma")
+	}
+
+	params := strings.Split(header, ";")
+	if mt := strings.TrimSpace(params[0]); !strings.EqualFold(mt, "image/png") {
+		return "", nil, fmt.Errorf("data URI has media type %q, expected image/png", mt)
+	}
+	if params[len(params)-1] != "base64" {
+		return "", nil, errors.New("data URI is not base64-encoded")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("data URI: %w", err)
+	}
+	return header, data, nil
+}
+
+// EmbedDataURI is like `EmbedTEXT` but takes and returns the png as a
+// `data:image/png;base64,...` URI.  The media type must be image/png and the
+// payload base64-encoded; the URI's header, parameters included, is kept as
+// it is.
+func EmbedDataURI(uri string, k string, v interface{}, opts ...Option) (string, error) {
+	header, data, err := parseDataURI(uri)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := EmbedTEXT(data, k, v, opts...)
+	if err != nil {
+		return "", err
+	}
+	return "data:" + header + "," + base64.StdEncoding.EncodeToString(out), nil
+}
+
+// ExtractDataURI is like `ExtractAll` but takes the png as a
+// `data:image/png;base64,...` URI.  URIs of another media type, without base64
+// encoding, or which are otherwise malformed are rejected.
+func ExtractDataURI(uri string, opts ...Option) (map[string][]byte, error) {
+	_, data, err := parseDataURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	return ExtractAll(data, opts...)
+}
@@ -0,0 +1,73 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestEmbedDataURI(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+	b64 := base64.StdEncoding.EncodeToString(bs)
+
+	// Negative test cases.
+	for _, uri := range []string{
+		"image/png;base64," + b64,
+		"data:image/png;base64" + b64,
+		"data:image/jpeg;base64," + b64,
+		"data:image/png," + b64,
+		"data:image/png;base64,!!!",
+		"data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("not a png")),
+	} {
+		if _, err := EmbedDataURI(uri, "Key", "Value"); err == nil {
+			t.Errorf("Expected error for %.32s..., got nil!\n", uri)
+		}
+	}
+
+	// Positive test cases.
+	out, err := EmbedDataURI("data:image/png;name=red.png;base64,"+b64, "Key", "Value")
+	fatalIfError(t, err)
+	if !strings.HasPrefix(out, "data:image/png;name=red.png;base64,") {
+		t.Errorf("Expected the URI header to be kept, got %.48s...\n", out)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(out[strings.IndexByte(out, ',')+1:])
+	fatalIfError(t, err)
+	m, err := ExtractTEXT(data)
+	fatalIfError(t, err)
+	if string(m["Key"]) != "Value" {
+		t.Errorf("Expected Value, got %s\n", m["Key"])
+	}
+}
+
+func TestExtractDataURI(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+	uri := "data:image/png;base64," + base64.StdEncoding.EncodeToString(bs)
+
+	uri, err = EmbedDataURI(uri, "Key", map[string]int{"n": 1})
+	fatalIfError(t, err)
+
+	m, err := ExtractDataURI(uri)
+	fatalIfError(t, err)
+	if string(m["Key"]) != `{"n":1}` {
+		t.Errorf("Expected {\"n\":1}, got %s\n", m["Key"])
+	}
+
+	// Negative test cases.
+	for _, uri := range []string{
+		"data:text/plain;base64,aGVsbG8=",
+		"data:image/png;base64,aGVsbG8=",
+		"data:image/png;base64",
+	} {
+		if _, err := ExtractDataURI(uri); err == nil {
+			t.Errorf("Expected error for %s, got nil!\n", uri)
+		}
+	}
+}
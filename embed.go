@@ -4,16 +4,13 @@ package pngembed
 ////////////////////////////////////////////////////////////////////////////////
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"hash/crc32"
-	"io"
-	"io/ioutil"
-	"strings"
+	"os"
 
 	"github.com/sabhiram/pngr"
 )
@@ -22,12 +19,88 @@ import (
 
 var (
 	pngMagic = []byte{137, 80, 78, 71, 13, 10, 26, 10}
+
+	// ErrNotPNG is returned (wrapped) when the input is not a png byte stream.
+	ErrNotPNG = errors.New("not a png")
+
+	// ErrTrailingData is returned (wrapped) when bytes follow the IEND chunk.
+	// Use `TrimAfterIEND` to remove them.
+	ErrTrailingData = errors.New("data after IEND chunk")
+
+	// ErrTooManyChunks is returned (wrapped) by the extractors when the input
+	// holds more chunks than allowed by `WithMaxChunks`.
+	ErrTooManyChunks = errors.New("too many chunks")
+
+	// ErrFileRead is returned (wrapped) by the file helpers when the png file
+	// could not be read.
+	ErrFileRead = errors.New("failed to read png file")
+
+	// ErrChunkTruncated is returned (wrapped) when the input ends in the
+	// middle of a chunk.
+	ErrChunkTruncated = errors.New("chunk truncated")
+
+	// ErrIHDRNotFirst is returned (wrapped) when the first chunk of the input
+	// is not IHDR, as written by some broken producers.  Embedding with
+	// `WithIHDRRepair` moves IHDR to the front instead.
+	ErrIHDRNotFirst = errors.New("IHDR is not the first chunk")
+
+	// ErrCRCMismatch is reported by `VerifyAllCRCs` for a complete chunk
+	// whose stored CRC does not match the IEEE CRC of its type and data.
+	ErrCRCMismatch = errors.New("CRC mismatch")
+
+	// ErrDuplicateIHDR is reported by `Repair` for each IHDR chunk after the
+	// first.
+	ErrDuplicateIHDR = errors.New("duplicate IHDR chunk")
 )
 
 const NULL_SEPERATOR byte = 0
 
 ////////////////////////////////////////////////////////////////////////////////
 
+// fileReadError wraps the error encountered while reading the png file at
+// `path`.  It matches `ErrFileRead` as well as the underlying error.
+type fileReadError struct {
+	path string
+	err  error
+}
+
+func (e *fileReadError) Error() string {
+	return fmt.Sprintf("%s (%s): %s", ErrFileRead, e.path, e.err)
+}
+
+func (e *fileReadError) Is(target error) bool {
+	return target == ErrFileRead
+}
+
+func (e *fileReadError) Unwrap() error {
+	return e.err
+}
+
+// readFile reads the file at `fp`, wrapping any error with the path and
+// `ErrFileRead`.
+func readFile(fp string) ([]byte, error) {
+	data, err := os.ReadFile(fp)
+	if err != nil {
+		return nil, &fileReadError{path: fp, err: err}
+	}
+	return data, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// checkSignature returns an error wrapping `ErrNotPNG` if `data` is too short
+// to hold the png magic number or does not begin with it.
+func checkSignature(data []byte) error {
+	if len(data) < len(pngMagic) {
+		return fmt.Errorf("%w: input is %d bytes, need at least %d for the png signature",
+			ErrNotPNG, len(data), len(pngMagic))
+	}
+	if err := errIfNotSubStr(data, pngMagic); err != nil {
+		return fmt.Errorf("%w: missing png file header", ErrNotPNG)
+	}
+	return nil
+}
+
 // Returns nil if sub is contained in s, an error otherwise.
 func errIfNotSubStr(s, sub []byte) error {
 	if len(sub) > len(s) {
@@ -69,49 +142,127 @@ func buildChunk(ct string, data []byte) ([]byte, error) {
 		return nil, fmt.Errorf("invalid chunk type (%s)", ct)
 	}
 
-	szbs := make([]byte, 4)
-	binary.BigEndian.PutUint32(szbs, uint32(len(data)))
+	// Checksum the type and data as they are, rather than concatenating them
+	// first, so the data is only copied once: into the chunk itself.
+	crc := crc32.New(crc32.IEEETable)
+	crc.Write([]byte(ct))
+	crc.Write(data)
+
+	out := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(out, uint32(len(data)))
+	copy(out[4:], ct)
+	out = append(out, data...)
+	return binary.BigEndian.AppendUint32(out, crc.Sum32()), nil
+}
 
-	bb := append([]byte(ct), data...)
+// embed verifies that the input data slice actually describes a PNG image, and
+// embeds the given png chunk into the png file.  The insertion point is found
+// by walking the chunks for the anchor the chunk's type must follow.
+func embed(data []byte, chunk []byte) ([]byte, error) {
+	return embedAt(data, chunk, AfterIHDR)
+}
 
-	crcbs := make([]byte, 4)
-	binary.BigEndian.PutUint32(crcbs, crc32.ChecksumIEEE(bb))
+// embed is like the `embed` function but places the chunk as selected by
+// `WithPlacement`, after moving IHDR to the front under `WithIHDRRepair`.
+func (o *options) embed(data []byte, chunk []byte) ([]byte, error) {
+	if o.repairIHDR {
+		var err error
+		if data, err = moveIHDRFirst(data); err != nil {
+			return nil, err
+		}
+	}
+	return embedAt(data, chunk, o.placement)
+}
 
-	bb = append(bb, crcbs...)
+// embedOffset validates the png data and returns the byte offset at which
+// `embedAt` injects a chunk of type `ct` under placement `p`.
+func embedOffset(data []byte, ct string, p Placement) (int, error) {
+	chunks, err := scanChunks(data)
+	if err != nil {
+		return 0, err
+	}
+	if n := trailingBytes(data, chunks); n > 0 {
+		return 0, fmt.Errorf("%w: %d bytes", ErrTrailingData, n)
+	}
+	return placementOffset(chunks, ct, p)
+}
 
-	// Prepend the length to the payload.
-	return append(szbs, bb...), nil
+// InjectionOffset returns the byte offset at which the embed functions insert
+// new text chunks into the PNG data by default, right after the IHDR chunk.
+// The data is validated as when embedding, so an error is returned wherever
+// embedding would fail.  Editors can use it to show the insertion position.
+func InjectionOffset(data []byte) (int, error) {
+	return embedOffset(data, "tEXt", AfterIHDR)
 }
 
-// embed verifies that the input data slice actually describes a PNG image, and
-// embeds the given png chunk into the png file
-func embed(data []byte, chunk []byte) ([]byte, error) {
-	out := []byte{}
-	buf := bytes.NewBuffer(data)
+// embedAt embeds the given png chunk into the png file, placed according to
+// `p`.
+func embedAt(data []byte, chunk []byte, p Placement) ([]byte, error) {
+	off, err := embedOffset(data, string(chunk[4:8]), p)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(data)+len(chunk))
+	out = append(out, data[:off]...)
+	out = append(out, chunk...)
+	return append(out, data[off:]...), nil
+}
 
-	// Magic number.
-	d := buf.Next(len(pngMagic))
-	out = append(out, d...)
-	err := errIfNotSubStr(pngMagic, d)
+// embedWithOptions embeds the png chunk carrying `v` under `k` into the png
+// data, and then applies the options that add further chunks of their own.
+func embedWithOptions(data []byte, pngChunk []byte, k string, v interface{}, o *options) ([]byte, error) {
+	out, err := o.embed(data, pngChunk)
 	if err != nil {
 		return nil, err
 	}
+	return o.postEmbed(out, []KV{{Key: k, Value: v}})
+}
 
-	// Extract header length, the header type should always be the first, we
-	// inject our custom text data right after this.
-	d = buf.Next(4)
-	out = append(out, d...)
-	sz := binary.BigEndian.Uint32(d)
+// postEmbed applies the options which add chunks of their own to the png data
+// embedded with `kvs`.  All such chunks are written in a single pass, so the
+// image is copied the same number of times regardless of how many keys carry
+// type hints.
+func (o *options) postEmbed(data []byte, kvs []KV) ([]byte, error) {
+	texts := [][2]string{}
+	if o.typeHints {
+		for _, kv := range kvs {
+			if name, ok := typeHint(kv.Value); ok {
+				texts = append(texts, [2]string{kv.Key + TypeHintSuffix, name})
+			}
+		}
+	}
+	if len(o.software) > 0 {
+		texts = append(texts, [2]string{"Software", o.software})
+	}
+	if len(texts) == 0 {
+		return data, nil
+	}
+	return replaceTEXT(data, texts...)
+}
 
-	// Extract the header tag, data, and CRC (for the header).
-	d = buf.Next(int(sz + 8))
-	out = append(out, d...)
+// replaceTEXT replaces any `tEXt` chunks with the keyword of each of `texts`
+// in the png data with a single one holding its text.
+func replaceTEXT(data []byte, texts ...[2]string) ([]byte, error) {
+	keys := map[string]bool{}
+	block := []byte{}
+	for _, kt := range texts {
+		if err := ValidateKeyword(kt[0]); err != nil {
+			return nil, err
+		}
+		keys[kt[0]] = true
 
-	// Append tEXt chunk.
-	out = append(out, chunk...)
+		pngChunk, _ := buildChunk(`tEXt`, formatTEXTChunk([]byte(kt[1]), kt[0]))
+		block = append(block, pngChunk...)
+	}
 
-	// Add the rest of the actual palette and data info.
-	return append(out, buf.Bytes()...), nil
+	data, err := stripText(data, func(ct, ck string) bool {
+		return ct == "tEXt" && keys[ck]
+	})
+	if err != nil {
+		return nil, err
+	}
+	return embed(data, block)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -120,21 +271,51 @@ func embed(data []byte, chunk []byte) ([]byte, error) {
 // pair into a `tEXt` chunk.  The resultant PNG byte-stream is returned, or an
 // error.  The interface `v` is serialized to known types and then to JSON if
 // all else fails.
-func EmbedTEXT(data []byte, k string, v interface{}) ([]byte, error) {
-	var (
-		err error
-		val []byte
-	)
+//
+// A value which serializes to no bytes, such as "", yields a valid chunk
+// holding just the keyword.  Keywords must be valid per `ValidateKeyword`, so
+// an empty keyword is rejected with `ErrKeywordEmpty`.
+//
+// Every call copies the whole image; to embed several keys at once, use
+// `EmbedMulti` or `EmbedPairs`, which copy it only once.
+func EmbedTEXT(data []byte, k string, v interface{}, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	k, err := o.keyword(k, false)
+	if err != nil {
+		return nil, err
+	}
 
-	val, err = to_bytes(v)
+	data = o.stripBOM(data)
 
+	val, promote, err := o.serializePromoting(v)
 	if err != nil {
 		return nil, err
 	}
+	if promote {
+		pngChunk, _ := buildChunk(`iTXt`, formatITXTChunk(val, k, CompressionFlagOff, CompressionNone, "", ""))
+		return embedWithOptions(data, pngChunk, k, v, o)
+	}
+
 	tEXtChunk := formatTEXTChunk(val, k)
 	pngChunk, _ := buildChunk(`tEXt`, tEXtChunk)
 
-	return embed(data, pngChunk)
+	return embedWithOptions(data, pngChunk, k, v, o)
+}
+
+// EmbedAndVerify is like `EmbedTEXT` but then extracts all text from the
+// result, returning it alongside the output so a round trip can be asserted
+// in a single call.
+func EmbedAndVerify(data []byte, k string, v interface{}, opts ...Option) ([]byte, map[string][]byte, error) {
+	out, err := EmbedTEXT(data, k, v, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, err := ExtractAll(out)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, m, nil
 }
 
 func to_bytes(v interface{}) ([]byte, error) {
@@ -150,15 +331,27 @@ func to_bytes(v interface{}) ([]byte, error) {
 	case string:
 		val = []byte(vt)
 	default:
-		val, err = json.Marshal(v)
+		val, err = marshalJSON(v)
 	}
 	return val, err
 }
 
+// marshalJSON is like `json.Marshal` but leaves `<`, `>` and `&` verbatim
+// rather than HTML-escaping them, since embedded values are not HTML.
+func marshalJSON(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
 // EmbedFile is like `Embed` but accepts the path to a PNG file.
 // Embeds to a file's tEXt chunk
 func EmbedTEXTInFile(fp, k string, v interface{}) ([]byte, error) {
-	data, err := ioutil.ReadFile(fp)
+	data, err := readFile(fp)
 	if err != nil {
 		return nil, err
 	}
@@ -169,100 +362,126 @@ func EmbedTEXTInFile(fp, k string, v interface{}) ([]byte, error) {
 ////////////////////////////////////////////////////////////////////////////////
 
 // Extract processes a stream of raw PNG data, and returns a map of `tEXt`
-// records encoded by this library.
-func ExtractTEXT(data []byte) (map[string][]byte, error) {
-	ret := map[string][]byte{}
-
-	r, err := pngr.NewReader(data, &pngr.ReaderOptions{
-		IncludedChunkTypes: []string{`tEXt`},
-	})
-	if err != nil {
+// records encoded by this library.  Data in which the `tEXt` chunk type does
+// not occur at all returns an empty map after a structural check of its
+// chunks, which rejects truncated data but, unlike the full read, does not
+// verify the CRCs.
+func ExtractTEXT(data []byte, opts ...Option) (map[string][]byte, error) {
+	o := newOptions(opts)
+	data = o.stripBOM(data)
+	if err := checkSignature(data); err != nil {
 		return nil, err
 	}
 
-	c, err := r.Next()
-	for ; err == nil; c, err = r.Next() {
-		sz := len(c.Data)
-		pt := strings.Index(string(c.Data), string(0))
-		if pt < sz {
-			ret[string(c.Data[:pt])] = c.Data[pt+1:]
+	// Fast path for the common case of a file without metadata.
+	if !bytes.Contains(data[len(pngMagic):], []byte(`tEXt`)) {
+		if _, err := scanChunksLimited(data, maxChunkLength, o.maxChunks); err != nil {
+			return nil, err
 		}
+		return map[string][]byte{}, nil
 	}
-	if err == io.EOF {
-		err = nil
-	}
-
-	return ret, err
-}
 
-func readNullTerminated(r *bufio.Reader) (string, error) {
-	data, err := r.ReadBytes(NULL_SEPERATOR)
+	r, err := pngr.NewReader(data, nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return string(data[:len(data)-1]), nil // strip the null terminator
+	return extractTEXTFromReader(r, o)
 }
 
-// Returns all itxt text fields and their keyword in a (keyword, text) map
-func ExtractITXT(data []byte) (map[string][]byte, error) {
-	ret := map[string][]byte{}
+// Returns all itxt text fields and their keyword in a (keyword, text) map.
+// Language tags and translated keywords are not part of the map; use
+// `ExtractITXTFull` to read them.
+func ExtractITXT(data []byte, opts ...Option) (map[string][]byte, error) {
+	o := newOptions(opts)
+	data = o.stripBOM(data)
+	if err := checkSignature(data); err != nil {
+		return nil, err
+	}
 
-	r, err := pngr.NewReader(data, &pngr.ReaderOptions{
-		IncludedChunkTypes: []string{`iTXt`},
-	})
+	r, err := pngr.NewReader(data, nil)
 	if err != nil {
 		return nil, err
 	}
+	return extractITXTFromReader(r, o)
+}
 
-	c, err := r.Next()
-	for ; err == nil; c, err = r.Next() {
-		br := bufio.NewReader(bytes.NewReader(c.Data))
-		keyword, err := readNullTerminated(br)
-		if err != nil {
-			return nil, err
-		}
+// itxtRecord holds the fields of a parsed iTXt chunk.
+type itxtRecord struct {
+	keyword           string
+	compressionFlag   byte
+	compressionMethod byte
+	languageTag       string
+	translatedKeyword string
+	text              []byte
+}
 
-		// 2. Compression flag (1 byte)
-		if _, err := br.Discard(1); err != nil {
-			return nil, fmt.Errorf("discard compression flag: %w", err)
-		}
+// parseITXT splits the data of an iTXt chunk into its keyword and text.
+func parseITXT(data []byte) (string, []byte, error) {
+	rec, err := parseITXTRecord(data)
+	if err != nil {
+		return "", nil, err
+	}
+	return rec.keyword, rec.text, nil
+}
 
-		// 3. Compression method (1 byte)
-		if _, err := br.Discard(1); err != nil {
-			return nil, fmt.Errorf("discard compression method: %w", err)
-		}
+// errITXTTooShort reports an iTXt chunk which ends before `field` is complete.
+func errITXTTooShort(field string) error {
+	return fmt.Errorf("iTXt chunk too short for %s", field)
+}
 
-		// 4. Consume Language tag including null-sep
-		_, err = br.ReadBytes(NULL_SEPERATOR)
-		if err != nil {
-			return nil, fmt.Errorf("read language tag: %w", err)
+// parseITXTRecord parses all fields of an iTXt chunk.  The text is returned as
+// stored, compressed or not, and aliases `data`.  A chunk which ends before
+// any of the fields preceding the text is complete is rejected with an error
+// naming the field.
+func parseITXTRecord(data []byte) (*itxtRecord, error) {
+	rec := &itxtRecord{}
+
+	// nextField splits off the null-terminated field at the front of `data`.
+	nextField := func(field string) (string, error) {
+		pt := bytes.IndexByte(data, NULL_SEPERATOR)
+		if pt < 0 {
+			return "", errITXTTooShort(field)
 		}
+		f := string(data[:pt])
+		data = data[pt+1:]
+		return f, nil
+	}
 
-		// 5. consume Translated keyword including Null-sep
-		_, err = br.ReadBytes(NULL_SEPERATOR)
-		if err != nil {
-			return nil, fmt.Errorf("read translated keyword: %w", err)
-		}
+	// 1. Keyword including null-sep
+	var err error
+	if rec.keyword, err = nextField("keyword"); err != nil {
+		return nil, err
+	}
 
-		// 6. Remaining bytes = Text
-		textBytes, err := io.ReadAll(br)
-		if err != nil {
-			return nil, fmt.Errorf("read text: %w", err)
-		}
-		ret[keyword] = textBytes
+	// 2. Compression flag (1 byte) and 3. compression method (1 byte)
+	if len(data) < 1 {
+		return nil, errITXTTooShort("compression flag")
+	}
+	if len(data) < 2 {
+		return nil, errITXTTooShort("compression method")
+	}
+	rec.compressionFlag, rec.compressionMethod = data[0], data[1]
+	data = data[2:]
 
+	// 4. Language tag including null-sep
+	if rec.languageTag, err = nextField("language tag"); err != nil {
+		return nil, err
 	}
-	if err == io.EOF {
-		err = nil
+
+	// 5. Translated keyword including null-sep
+	if rec.translatedKeyword, err = nextField("translated keyword"); err != nil {
+		return nil, err
 	}
 
-	return ret, err
+	// 6. Remaining bytes = Text
+	rec.text = data
+	return rec, nil
 }
 
 // ExtractFile is like `Extract` but accepts the path to a PNG file.
 // Extrats the tEXt from the png
 func ExtractFileTEXT(fp string) (map[string][]byte, error) {
-	data, err := ioutil.ReadFile(fp)
+	data, err := readFile(fp)
 	if err != nil {
 		return nil, err
 	}
@@ -270,26 +489,31 @@ func ExtractFileTEXT(fp string) (map[string][]byte, error) {
 	return ExtractTEXT(data)
 }
 
-func EmbedITXT(data []byte, k string, v interface{}) ([]byte, error) {
-	var (
-		err error
-		val []byte
-	)
+func EmbedITXT(data []byte, k string, v interface{}, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	k, err := o.keyword(k, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var val []byte
+
+	data = o.stripBOM(data)
 
-	val, err = to_bytes(v)
+	val, err = o.serialize(v)
 
 	if err != nil {
 		return nil, err
 	}
-	compression_flag := 0
-	compression_method := 0
+	compression_flag := CompressionFlagOff
+	compression_method := CompressionNone
 	language_tag := ""
 	translate_keyword := ""
 
 	iTXtChunk := formatITXTChunk(val, k, compression_flag, compression_method, language_tag, translate_keyword)
 	pngChunk, _ := buildChunk(`iTXt`, iTXtChunk)
 
-	return embed(data, pngChunk)
+	return embedWithOptions(data, pngChunk, k, v, o)
 
 }
 
@@ -3,10 +3,13 @@ package pngembed
 ////////////////////////////////////////////////////////////////////////////////
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -47,7 +50,7 @@ func TestEmbed(t *testing.T) {
 		{data: bs, k: "Key", v: 42.0, isErr: false},
 		{data: bs, k: "Key", v: struct{}{}, isErr: false},
 	} {
-		out, err := Embed(tc.data, tc.k, tc.v)
+		out, err := EmbedTEXT(tc.data, tc.k, tc.v)
 		if tc.isErr == false {
 			fatalIfError(t, err)
 		} else {
@@ -77,7 +80,7 @@ func TestEmbed(t *testing.T) {
 			t.Errorf("Expected buffer size %d, got %d\n", exp, act)
 		}
 
-		m, err := Extract(out)
+		m, err := ExtractTEXT(out)
 		fatalIfError(t, err)
 
 		// We should have one key titled "Key".
@@ -106,7 +109,7 @@ func TestEmbedFile(t *testing.T) {
 		// Positive test cases.
 		{fp: redPng, k: "Key0", v: "Value0", isErr: false},
 	} {
-		out, err := EmbedFile(tc.fp, tc.k, tc.v)
+		out, err := EmbedTEXTInFile(tc.fp, tc.k, tc.v)
 		if tc.isErr == false {
 			fatalIfError(t, err)
 		} else {
@@ -179,7 +182,7 @@ func TestSubstring(t *testing.T) {
 }
 
 func TestBadExtract(t *testing.T) {
-	m1, err := Extract([]byte{1, 2, 3})
+	m1, err := ExtractTEXT([]byte{1, 2, 3})
 	if err == nil {
 		t.Errorf("Expected error, got nil\n")
 	}
@@ -190,14 +193,14 @@ func TestBadExtract(t *testing.T) {
 }
 
 func TestExtractFile(t *testing.T) {
-	m1, err := ExtractFile(redPng)
+	m1, err := ExtractFileTEXT(redPng)
 	fatalIfError(t, err)
 
 	if len(m1) != 0 {
 		t.Errorf("Expected 0 encoded items, got %d\n", len(m1))
 	}
 
-	m2, err := ExtractFile("blue.png")
+	m2, err := ExtractFileTEXT("blue.png")
 	if err == nil {
 		t.Errorf("Expected error, got nil\n")
 	}
@@ -205,3 +208,289 @@ func TestExtractFile(t *testing.T) {
 		t.Errorf("Expected nil reader, got non-nil value\n")
 	}
 }
+
+func TestFileReadError(t *testing.T) {
+	_, err := EmbedTEXTInFile("missing.png", "Key", "Value")
+	if !errors.Is(err, ErrFileRead) {
+		t.Errorf("Expected ErrFileRead, got %v\n", err)
+	}
+
+	_, err = ExtractFileTEXT("missing.png")
+	if !errors.Is(err, ErrFileRead) {
+		t.Errorf("Expected ErrFileRead, got %v\n", err)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected os.ErrNotExist, got %v\n", err)
+	}
+	if err != nil && !strings.Contains(err.Error(), "missing.png") {
+		t.Errorf("Expected error to name the file, got %v\n", err)
+	}
+}
+
+func TestShortInput(t *testing.T) {
+	for _, data := range [][]byte{
+		{},
+		{137, 80, 78},
+	} {
+		_, err := EmbedTEXT(data, "Key", "Value")
+		if !errors.Is(err, ErrNotPNG) {
+			t.Errorf("Expected ErrNotPNG, got %v\n", err)
+		}
+		if err != nil && !strings.Contains(err.Error(), fmt.Sprintf("%d bytes", len(data))) {
+			t.Errorf("Expected error to name the input length, got %v\n", err)
+		}
+
+		for _, extract := range []func([]byte, ...Option) (map[string][]byte, error){
+			ExtractTEXT, ExtractITXT, ExtractAll,
+		} {
+			if _, err := extract(data); !errors.Is(err, ErrNotPNG) {
+				t.Errorf("Expected ErrNotPNG, got %v\n", err)
+			}
+		}
+	}
+}
+
+func TestParseITXTTruncated(t *testing.T) {
+	// Keyword "Key", flag, method, language "en", translated "Clé", text "v".
+	full := formatITXTChunk([]byte("v"), "Key", 0, 0, "en", "Clé")
+
+	for _, tc := range []struct {
+		n     int
+		field string
+	}{
+		{n: 3, field: "keyword"},
+		{n: 4, field: "compression flag"},
+		{n: 5, field: "compression method"},
+		{n: 8, field: "language tag"},
+		{n: 13, field: "translated keyword"},
+	} {
+		_, err := parseITXTRecord(full[:tc.n])
+		if err == nil || err.Error() != "iTXt chunk too short for "+tc.field {
+			t.Errorf("Expected error for %s at %d bytes, got %v\n", tc.field, tc.n, err)
+		}
+	}
+
+	rec, err := parseITXTRecord(full[:len(full)-1])
+	fatalIfError(t, err)
+	if len(rec.text) != 0 || rec.translatedKeyword != "Clé" {
+		t.Errorf("Unexpected record %+v\n", rec)
+	}
+
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+	out, err := EmbedChunk(bs, "iTXt", full[:4])
+	fatalIfError(t, err)
+	if _, err := ExtractITXT(out); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
+
+func BenchmarkExtractTEXTNoMetadata(b *testing.B) {
+	bs, err := ioutil.ReadFile(redPng)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ExtractTEXT(bs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestEmbedJSONNotHTMLEscaped(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	v := struct {
+		Expr string `json:"expr"`
+	}{Expr: "a < b && b > c"}
+
+	out, err := EmbedTEXT(bs, "Key", v)
+	fatalIfError(t, err)
+
+	m, err := ExtractTEXT(out)
+	fatalIfError(t, err)
+	exp := `{"expr":"a < b && b > c"}`
+	if string(m["Key"]) != exp {
+		t.Errorf("Expected %s, got %s\n", exp, m["Key"])
+	}
+}
+
+func BenchmarkBuildChunk10MB(b *testing.B) {
+	data := make([]byte, 10<<20)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		if _, err := buildChunk("iCCP", data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestEmbedAndVerify(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, m, err := EmbedAndVerify(bs, "Key", map[string]int{"n": 1})
+	fatalIfError(t, err)
+	if string(m["Key"]) != `{"n":1}` {
+		t.Errorf("Expected {\"n\":1}, got %s\n", m["Key"])
+	}
+	if exp, _ := EmbedTEXT(bs, "Key", map[string]int{"n": 1}); !bytes.Equal(out, exp) {
+		t.Errorf("Expected the output of EmbedTEXT\n")
+	}
+
+	if _, _, err := EmbedAndVerify([]byte{1, 2, 3}, "Key", "Value"); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
+
+// itxtFixture returns the red fixture carrying `n` iTXt chunks.
+func itxtFixture(tb testing.TB, n int) []byte {
+	bs, err := ioutil.ReadFile(redPng)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	block := []byte{}
+	for i := 0; i < n; i++ {
+		text, k := fmt.Sprintf("Value%d", i), fmt.Sprintf("Key%d", i)
+		c, _ := buildChunk(`iTXt`, formatITXTChunk([]byte(text), k, 0, 0, "en", ""))
+		block = append(block, c...)
+	}
+	out, err := embed(bs, block)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return out
+}
+
+func TestParseITXTRecordAllocs(t *testing.T) {
+	data := formatITXTChunk([]byte("Value"), "Key", 0, 0, "en", "")
+
+	// One allocation each for the record, the keyword and the language tag;
+	// the text is a slice of the chunk data.
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := parseITXTRecord(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs > 3 {
+		t.Errorf("Expected at most 3 allocations per parse, got %v\n", allocs)
+	}
+
+	m, err := ExtractITXT(itxtFixture(t, 100))
+	fatalIfError(t, err)
+	if len(m) != 100 || string(m["Key42"]) != "Value42" {
+		t.Errorf("Expected 100 records, got %d\n", len(m))
+	}
+}
+
+func BenchmarkExtractITXT100(b *testing.B) {
+	bs := itxtFixture(b, 100)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ExtractITXT(bs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestEmbedEmptyValueAndKey(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	embedders := map[string]func(data []byte, k string, v interface{}, opts ...Option) ([]byte, error){
+		"tEXt":            EmbedTEXT,
+		"iTXt":            EmbedITXT,
+		"zTXt":            EmbedZTXT,
+		"iTXt/compressed": EmbedITXTCompressed,
+	}
+	for name, embed := range embedders {
+		// Positive test cases: empty values yield valid chunks.
+		for _, tc := range []struct {
+			v   interface{}
+			exp string
+		}{
+			{v: "", exp: ""},
+			{v: struct{}{}, exp: "{}"},
+		} {
+			out, err := embed(bs, "Empty", tc.v)
+			fatalIfError(t, err)
+			rs, err := VerifyAllCRCs(out)
+			fatalIfError(t, err)
+			for _, r := range rs {
+				if !r.Valid {
+					t.Errorf("Expected valid CRC for %s at %d\n", r.Type, r.Offset)
+				}
+			}
+			v, found, err := GetValue(out, "Empty")
+			fatalIfError(t, err)
+			if !found || string(v) != tc.exp {
+				t.Errorf("%s: expected (%s) for %#v, got (%s) found=%v\n", name, tc.exp, tc.v, v, found)
+			}
+		}
+
+		// Negative test cases: empty keys are rejected, whatever the value.
+		for _, v := range []interface{}{"", "value"} {
+			if _, err := embed(bs, "", v); !errors.Is(err, ErrKeywordEmpty) {
+				t.Errorf("%s: expected ErrKeywordEmpty, got %v\n", name, err)
+			}
+		}
+	}
+
+	if _, err := EmbedPairs(bs, []KV{{Key: "", Value: ""}}); !errors.Is(err, ErrKeywordEmpty) {
+		t.Errorf("Expected ErrKeywordEmpty, got %v\n", err)
+	}
+	if _, err := EmbedMulti(bs, map[string]interface{}{"": 1}); !errors.Is(err, ErrKeywordEmpty) {
+		t.Errorf("Expected ErrKeywordEmpty, got %v\n", err)
+	}
+}
+
+func TestInjectionOffset(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	// Positive test cases.
+	off, err := InjectionOffset(bs)
+	fatalIfError(t, err)
+	if exp := 8 + 4 + 4 + 13 + 4; off != exp {
+		t.Errorf("Expected offset %d, got %d\n", exp, off)
+	}
+
+	out, err := EmbedTEXT(bs, "Key", "Value")
+	fatalIfError(t, err)
+	if !bytes.Equal(out[:off], bs[:off]) || string(out[off+4:off+8]) != "tEXt" {
+		t.Errorf("Expected the tEXt chunk at offset %d\n", off)
+	}
+
+	// Negative test cases.
+	for _, data := range [][]byte{bs[:20], append(append([]byte{}, bs...), 0)} {
+		if _, err := InjectionOffset(data); err == nil {
+			t.Errorf("Expected error, got nil!\n")
+		}
+	}
+}
+
+func TestExtractTEXTNoMetadataTruncated(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	// Positive test cases.
+	m, err := ExtractTEXT(bs)
+	fatalIfError(t, err)
+	if len(m) != 0 {
+		t.Errorf("Expected no records, got %v\n", m)
+	}
+
+	// Negative test cases: the fast path still rejects broken structure.
+	if _, err := ExtractTEXT(bs[:len(bs)-5]); !errors.Is(err, ErrChunkTruncated) {
+		t.Errorf("Expected ErrChunkTruncated, got %v\n", err)
+	}
+	if _, err := ExtractTEXT(bs, WithMaxChunks(1)); !errors.Is(err, ErrTooManyChunks) {
+		t.Errorf("Expected ErrTooManyChunks, got %v\n", err)
+	}
+}
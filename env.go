@@ -0,0 +1,91 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// envName turns a keyword into a shell identifier: ASCII letters are
+// uppercased, every other byte that is not an ASCII letter or digit becomes
+// `_`, and a leading digit is prefixed with `_`.  Keywords are Latin-1, so
+// they are mapped byte by byte.
+func envName(k string) string {
+	b := []byte(k)
+	for i, c := range b {
+		switch {
+		case c >= 'a' && c <= 'z':
+			b[i] = c - 'a' + 'A'
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		default:
+			b[i] = '_'
+		}
+	}
+	if len(b) > 0 && b[0] >= '0' && b[0] <= '9' {
+		return "_" + string(b)
+	}
+	return string(b)
+}
+
+// envValue returns `v` as is if the shell reads it as a single word, and
+// single-quoted otherwise.
+func envValue(v []byte) string {
+	safe := true
+	for _, c := range v {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			strings.IndexByte("_-.,:/+@%", c) >= 0) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return string(v)
+	}
+	return "'" + strings.ReplaceAll(string(v), "'", `'\''`) + "'"
+}
+
+// WriteEnvFile writes each text record of the PNG data to `w` as a `KEY=VALUE`
+// line, so the metadata can be `source`d by a shell script.  Keywords are
+// sanitized into shell identifiers by uppercasing them, replacing anything
+// other than an ASCII letter or digit with `_`, and prefixing a leading digit
+// with `_`.  Values made of anything but letters, digits and `_-.,:/+@%` are
+// single-quoted, which keeps newlines and spaces intact.  Lines are sorted by
+// name.  Precedence among repeated keywords follows `ExtractAll`; if two
+// distinct keywords sanitize to the same name, an error is returned naming
+// both, and nothing is written.
+func WriteEnvFile(w io.Writer, data []byte) error {
+	m, err := ExtractAll(data)
+	if err != nil {
+		return err
+	}
+
+	names := map[string]string{}
+	for k := range m {
+		n := envName(k)
+		if prev, ok := names[n]; ok {
+			if prev > k {
+				prev, k = k, prev
+			}
+			return fmt.Errorf("keywords %q and %q both map to %s", prev, k, n)
+		}
+		names[n] = k
+	}
+
+	sorted := make([]string, 0, len(names))
+	for n := range names {
+		sorted = append(sorted, n)
+	}
+	sort.Strings(sorted)
+
+	for _, n := range sorted {
+		if _, err := fmt.Fprintf(w, "%s=%s\n", n, envValue(m[names[n]])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestWriteEnvFile(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXT(bs, "build-id", "1.2.3")
+	fatalIfError(t, err)
+	out, err = EmbedTEXT(out, "2nd note", "it's\nmultiline")
+	fatalIfError(t, err)
+
+	buf := &bytes.Buffer{}
+	fatalIfError(t, WriteEnvFile(buf, out))
+
+	exp := "BUILD_ID=1.2.3\n_2ND_NOTE='it'\\''s\nmultiline'\n"
+	if buf.String() != exp {
+		t.Errorf("Expected %q, got %q\n", exp, buf.String())
+	}
+
+	// Latin-1 keywords map one byte to one `_`.
+	for k, exp := range map[string]string{"caf\xe9": "CAF_", "\xc9t\xe9": "_T_", "x-y": "X_Y"} {
+		if act := envName(k); act != exp {
+			t.Errorf("Expected %s for %q, got %s\n", exp, k, act)
+		}
+	}
+
+	// Negative test cases.
+	out, err = EmbedTEXT(out, "Build.ID", "4.5.6")
+	fatalIfError(t, err)
+	if err := WriteEnvFile(&bytes.Buffer{}, out); err == nil {
+		t.Errorf("Expected error for colliding names, got nil!\n")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"sort"
 
 	pngembed "github.com/deniz-dilaverler/png-embed"
 )
@@ -25,62 +26,191 @@ type SampleStruct struct {
 
 ////////////////////////////////////////////////////////////////////////////////
 
-var (
-	inputFile  string
-	outputFile string
-	key        string
-	value      string
-)
+// command describes a subcommand of the example binary.
+type command struct {
+	desc string
+	run  func(args []string) error
+}
+
+var commands = map[string]command{
+	"embed":   {desc: "embed a key-value pair into a png", run: runEmbed},
+	"extract": {desc: "print the text metadata of a png", run: runExtract},
+	"list":    {desc: "print the chunk types and sizes of a png", run: runList},
+	"strip":   {desc: "remove all text metadata from a png", run: runStrip},
+}
 
 ////////////////////////////////////////////////////////////////////////////////
 
+func usage() {
+	fmt.Printf("Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %-8s %s\n", name, commands[name].desc)
+	}
+	fmt.Printf("\nRun '%s <command> -h' for the flags of a command.\n", os.Args[0])
+}
+
 func main() {
-	s := SampleStruct{
-		StrVal:  "hello",
-		IntVal:  42,
-		BoolVal: true,
-		StructVal: InnerStruct{
-			InnerBool: false,
-			InnerStr:  "world",
-			InnerInt:  7,
-		},
-	}
-
-	input, err := os.ReadFile(inputFile)
-	if err != nil {
-		panic(err)
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
 	}
-	data, err := pngembed.EmbedITXT(input, key, s)
-	if err == nil {
-		file, err := os.Create(outputFile)
-		if err != nil {
-			panic(err)
-		}
-		defer file.Close()
 
-		// Write binary data
-		_, err = file.Write(data)
-		if err != nil {
-			panic(err)
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Printf("Unknown command: %s\n\n", os.Args[1])
+		usage()
+		os.Exit(1)
+	}
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Printf("Fatal error: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// parseFlags parses `args` into `fs` and ensures an input file was given.
+func parseFlags(fs *flag.FlagSet, args []string, inputFile *string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(*inputFile) == 0 {
+		return fmt.Errorf("no input file specified")
+	}
+	return nil
+}
+
+func runEmbed(args []string) error {
+	var (
+		fs         = flag.NewFlagSet("embed", flag.ExitOnError)
+		inputFile  = fs.String("input", "image.png", "input file name for the png")
+		outputFile = fs.String("output", "out.png", "output file name for the png")
+		key        = fs.String("key", "TEST_KEY", "key name for the data to inject")
+		value      = fs.String("value", "TEST_VALUE", "value for the data to inject")
+		itxt       = fs.Bool("itxt", false, "store the value in an iTXt chunk instead of tEXt")
+		sample     = fs.Bool("sample", false, "inject a sample struct as JSON instead of -value")
+	)
+	if err := parseFlags(fs, args, inputFile); err != nil {
+		return err
+	}
+
+	var v interface{} = *value
+	if *sample {
+		v = SampleStruct{
+			StrVal:  "hello",
+			IntVal:  42,
+			BoolVal: true,
+			StructVal: InnerStruct{
+				InnerBool: false,
+				InnerStr:  "world",
+				InnerInt:  7,
+			},
 		}
-		fmt.Printf("File '%s' was successfully embedded and saved as '%s' \n", inputFile, outputFile)
 	}
 
-	mapping, err := pngembed.ExtractITXT(data)
-	fmt.Printf("keyword: %s \n", key)
-	fmt.Printf("text value: %s \n", mapping[key])
+	input, err := os.ReadFile(*inputFile)
+	if err != nil {
+		return err
+	}
+
+	embed := pngembed.EmbedTEXT
+	if *itxt {
+		embed = pngembed.EmbedITXT
+	}
+	data, err := embed(input, *key, v)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(*outputFile, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("File '%s' was successfully embedded and saved as '%s'\n", *inputFile, *outputFile)
+	return nil
+}
+
+func runExtract(args []string) error {
+	var (
+		fs        = flag.NewFlagSet("extract", flag.ExitOnError)
+		inputFile = fs.String("input", "image.png", "input file name for the png")
+	)
+	if err := parseFlags(fs, args, inputFile); err != nil {
+		return err
+	}
+
+	input, err := os.ReadFile(*inputFile)
+	if err != nil {
+		return err
+	}
+	m, err := pngembed.ExtractAll(input)
+	if err != nil {
+		return err
+	}
 
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("%s: %s\n", k, m[k])
+	}
+	return nil
 }
 
-func init() {
-	flag.StringVar(&inputFile, "input", "image.png", "input file name for the png")
-	flag.StringVar(&outputFile, "output", "out.png", "output file name for the png")
-	flag.StringVar(&key, "key", "TEST_KEY", "key name for the data to inject")
-	// flag.StringVar(&value, "value", "TEST_VALUE", "sample value to inject for key")
+func runList(args []string) error {
+	var (
+		fs        = flag.NewFlagSet("list", flag.ExitOnError)
+		inputFile = fs.String("input", "image.png", "input file name for the png")
+	)
+	if err := parseFlags(fs, args, inputFile); err != nil {
+		return err
+	}
 
-	flag.Parse()
-	if len(inputFile) == 0 {
-		fmt.Printf("Fatal error: No input file specified!\n")
-		os.Exit(1)
+	input, err := os.ReadFile(*inputFile)
+	if err != nil {
+		return err
+	}
+	cis, err := pngembed.ListChunks(input)
+	if err != nil {
+		return err
+	}
+
+	for _, ci := range cis {
+		fmt.Printf("%s %8d bytes at offset %d\n", ci.Type, ci.Length, ci.Offset)
+	}
+	return nil
+}
+
+func runStrip(args []string) error {
+	var (
+		fs         = flag.NewFlagSet("strip", flag.ExitOnError)
+		inputFile  = fs.String("input", "image.png", "input file name for the png")
+		outputFile = fs.String("output", "out.png", "output file name for the png")
+	)
+	if err := parseFlags(fs, args, inputFile); err != nil {
+		return err
+	}
+
+	input, err := os.ReadFile(*inputFile)
+	if err != nil {
+		return err
+	}
+	data, err := pngembed.StripAllText(input)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(*outputFile, data, 0644); err != nil {
+		return err
 	}
+	fmt.Printf("File '%s' was stripped of text metadata and saved as '%s'\n", *inputFile, *outputFile)
+	return nil
 }
@@ -0,0 +1,140 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// OrientationKey is the text keyword under which tools may record the
+// orientation of an image, alongside its EXIF orientation.
+const OrientationKey = "pngembed:orientation"
+
+const (
+	exifOrientationTag = 0x0112
+	exifTypeShort      = 3
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ExtractOrientation returns the EXIF orientation (1 through 8) recorded in
+// the eXIf chunk of the PNG data.  Only the TIFF header and IFD0 are read; an
+// error is returned if there is no eXIf chunk or it carries no orientation.
+func ExtractOrientation(data []byte) (int, error) {
+	exif, err := findEXIF(data)
+	if err != nil {
+		return 0, err
+	}
+	if exif == nil {
+		return 0, errors.New("missing eXIf chunk")
+	}
+	return exifOrientation(exif)
+}
+
+// findEXIF returns the data of the eXIf chunk in the png data, or nil if there
+// is none.
+func findEXIF(data []byte) ([]byte, error) {
+	chunks, err := newOptions(nil).scanChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	i := indexOfChunk(chunks, "eXIf")
+	if i < 0 {
+		return nil, nil
+	}
+	return chunks[i].data(data), nil
+}
+
+// ValidateOrientationConsistency checks that, when the PNG data carries both
+// an EXIF orientation and a `pngembed:orientation` text record, the two
+// agree.  An error describing the discrepancy is returned if they do not.  If
+// either is absent there is nothing to compare and nil is returned.
+func ValidateOrientationConsistency(data []byte) error {
+	exif, err := findEXIF(data)
+	if err != nil {
+		return err
+	}
+	hint, found, err := GetValue(data, OrientationKey)
+	if err != nil {
+		return err
+	}
+	if exif == nil || !found {
+		return nil
+	}
+
+	eo, err := exifOrientation(exif)
+	if err != nil {
+		return err
+	}
+	to, err := strconv.Atoi(strings.TrimSpace(string(hint)))
+	if err != nil {
+		return fmt.Errorf("%s (%q) is not an orientation", OrientationKey, hint)
+	}
+	if eo != to {
+		return fmt.Errorf("orientation mismatch: eXIf has %d, %s has %d", eo, OrientationKey, to)
+	}
+	return nil
+}
+
+// exifOrientation reads the orientation tag from IFD0 of the TIFF-formatted
+// EXIF block `exif`.
+func exifOrientation(exif []byte) (int, error) {
+	// -----------------------------------------------------
+	// | Byte order | Magic (42) | Offset of IFD0          |
+	// -----------------------------------------------------
+	// | "II"/"MM"  | 2 bytes    | 4 bytes                 |
+	if len(exif) < 8 {
+		return 0, errors.New("eXIf chunk too short for TIFF header")
+	}
+
+	var bo binary.ByteOrder
+	switch string(exif[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return 0, errors.New("eXIf chunk has an invalid byte order")
+	}
+	if bo.Uint16(exif[2:4]) != 42 {
+		return 0, errors.New("eXIf chunk has an invalid TIFF magic")
+	}
+
+	// IFD0 is a 2 byte entry count followed by 12 byte entries:
+	// ------------------------------------------
+	// | Tag     | Type    | Count   | Value    |
+	// ------------------------------------------
+	// | 2 bytes | 2 bytes | 4 bytes | 4 bytes  |
+	off := int(bo.Uint32(exif[4:8]))
+	if off < 8 || off > len(exif)-2 {
+		return 0, errors.New("eXIf chunk has an invalid IFD0 offset")
+	}
+	n := int(bo.Uint16(exif[off : off+2]))
+	off += 2
+	if n > (len(exif)-off)/12 {
+		return 0, errors.New("eXIf chunk too short for IFD0 entries")
+	}
+
+	for i := 0; i < n; i++ {
+		e := exif[off+12*i : off+12*(i+1)]
+		if bo.Uint16(e[0:2]) != exifOrientationTag {
+			continue
+		}
+		if bo.Uint16(e[2:4]) != exifTypeShort || bo.Uint32(e[4:8]) != 1 {
+			return 0, errors.New("eXIf orientation tag has an unexpected type")
+		}
+		o := int(bo.Uint16(e[8:10]))
+		if o < 1 || o > 8 {
+			return 0, fmt.Errorf("eXIf orientation (%d) out of range", o)
+		}
+		return o, nil
+	}
+	return 0, errors.New("eXIf chunk has no orientation tag")
+}
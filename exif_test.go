@@ -0,0 +1,114 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// exifBlock returns a minimal TIFF-formatted EXIF block whose IFD0 holds a
+// single entry with the given tag and SHORT value.
+func exifBlock(bo binary.ByteOrder, tag, value uint16) []byte {
+	b := make([]byte, 8+2+12+4)
+	if bo == binary.LittleEndian {
+		copy(b, "II")
+	} else {
+		copy(b, "MM")
+	}
+	bo.PutUint16(b[2:], 42)
+	bo.PutUint32(b[4:], 8)
+	bo.PutUint16(b[8:], 1)
+	bo.PutUint16(b[10:], tag)
+	bo.PutUint16(b[12:], exifTypeShort)
+	bo.PutUint32(b[14:], 1)
+	bo.PutUint16(b[18:], value)
+	return b
+}
+
+func TestExtractOrientation(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	for _, tc := range []struct {
+		exif  []byte
+		exp   int
+		isErr bool
+	}{
+		// Negative test cases.
+		{exif: nil, isErr: true},
+		{exif: []byte("II*"), isErr: true},
+		{exif: exifBlock(binary.BigEndian, 0x010f, 6), isErr: true},
+		{exif: exifBlock(binary.BigEndian, exifOrientationTag, 9), isErr: true},
+
+		// Positive test cases.
+		{exif: exifBlock(binary.BigEndian, exifOrientationTag, 6), exp: 6, isErr: false},
+		{exif: exifBlock(binary.LittleEndian, exifOrientationTag, 6), exp: 6, isErr: false},
+	} {
+		data := bs
+		if tc.exif != nil {
+			data, err = EmbedChunk(bs, "eXIf", tc.exif)
+			fatalIfError(t, err)
+		}
+
+		o, err := ExtractOrientation(data)
+		if tc.isErr == false {
+			fatalIfError(t, err)
+		} else {
+			if err == nil {
+				t.Errorf("Expected error, got nil!\n")
+			}
+			continue
+		}
+		if o != tc.exp {
+			t.Errorf("Expected orientation %d, got %d\n", tc.exp, o)
+		}
+	}
+}
+
+func TestValidateOrientationConsistency(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	withEXIF, err := EmbedChunk(bs, "eXIf", exifBlock(binary.BigEndian, exifOrientationTag, 6))
+	fatalIfError(t, err)
+
+	for _, tc := range []struct {
+		data  []byte
+		hint  interface{}
+		isErr bool
+	}{
+		// Negative test cases.
+		{data: withEXIF, hint: 3, isErr: true},
+		{data: withEXIF, hint: "sideways", isErr: true},
+
+		// Positive test cases.
+		{data: withEXIF, hint: 6, isErr: false},
+		{data: withEXIF, hint: nil, isErr: false},
+		{data: bs, hint: 3, isErr: false},
+	} {
+		data := tc.data
+		if tc.hint != nil {
+			data, err = EmbedTEXT(data, OrientationKey, tc.hint)
+			fatalIfError(t, err)
+		}
+
+		err := ValidateOrientationConsistency(data)
+		if tc.isErr == false {
+			fatalIfError(t, err)
+		} else if err == nil {
+			t.Errorf("Expected error for hint %v, got nil!\n", tc.hint)
+		}
+	}
+
+	data, err := EmbedTEXT(withEXIF, OrientationKey, 3)
+	fatalIfError(t, err)
+	err = ValidateOrientationConsistency(data)
+	if err == nil || !strings.Contains(err.Error(), "eXIf has 6") || !strings.Contains(err.Error(), "has 3") {
+		t.Errorf("Expected error listing both orientations, got %v\n", err)
+	}
+}
@@ -0,0 +1,42 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ExpiresKey is the keyword of the tEXt chunk `EmbedExpiry` stores the expiry
+// time under.
+const ExpiresKey = "pngembed:expires"
+
+////////////////////////////////////////////////////////////////////////////////
+
+// EmbedExpiry stores `expiresAt` as an RFC 3339 timestamp in a tEXt chunk
+// keyed `pngembed:expires`, replacing any expiry already present.  Caches can
+// use it with `IsExpired` to tell when an image should be regenerated.
+func EmbedExpiry(data []byte, expiresAt time.Time) ([]byte, error) {
+	return replaceTEXT(data, [2]string{ExpiresKey, expiresAt.Format(time.RFC3339)})
+}
+
+// IsExpired returns true if the expiry stored by `EmbedExpiry` is not after
+// `now`.  An error is returned if the PNG data carries no expiry, or one that
+// is not an RFC 3339 timestamp.
+func IsExpired(data []byte, now time.Time) (bool, error) {
+	v, found, err := GetValue(data, ExpiresKey)
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		return false, fmt.Errorf("keyword (%s) not found", ExpiresKey)
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, string(v))
+	if err != nil {
+		return false, fmt.Errorf("keyword (%s): %w", ExpiresKey, err)
+	}
+	return !now.Before(expiresAt), nil
+}
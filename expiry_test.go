@@ -0,0 +1,52 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestExpiry(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	if _, err := IsExpired(bs, time.Now()); err == nil {
+		t.Errorf("Expected error without an expiry, got nil!\n")
+	}
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		expiresAt time.Time
+		exp       bool
+	}{
+		{expiresAt: now.Add(-time.Hour), exp: true},
+		{expiresAt: now.Add(time.Hour), exp: false},
+	} {
+		out, err := EmbedExpiry(bs, tc.expiresAt)
+		fatalIfError(t, err)
+		got, err := IsExpired(out, now)
+		fatalIfError(t, err)
+		if got != tc.exp {
+			t.Errorf("Expected %v for expiry at %s, got %v\n", tc.exp, tc.expiresAt, got)
+		}
+	}
+
+	// Re-embedding replaces the previous expiry.
+	out, err := EmbedExpiry(bs, now.Add(-time.Hour))
+	fatalIfError(t, err)
+	out, err = EmbedExpiry(out, now.Add(time.Hour))
+	fatalIfError(t, err)
+	if vs := textValues(t, out, ExpiresKey); len(vs) != 1 {
+		t.Errorf("Expected a single expiry, got %v\n", vs)
+	}
+
+	out, err = EmbedTEXT(bs, ExpiresKey, "tomorrow")
+	fatalIfError(t, err)
+	if _, err := IsExpired(out, now); err == nil {
+		t.Errorf("Expected error for a malformed expiry, got nil!\n")
+	}
+}
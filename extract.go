@@ -0,0 +1,463 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/sabhiram/pngr"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// parseTEXT splits the data of a tEXt chunk into its keyword and text.
+func parseTEXT(data []byte) (string, []byte, error) {
+	pt := bytes.IndexByte(data, NULL_SEPERATOR)
+	if pt < 0 {
+		return "", nil, errors.New("tEXt chunk is missing its null separator")
+	}
+	return string(data[:pt]), data[pt+1:], nil
+}
+
+// parseZTXT splits the data of a zTXt chunk into its keyword, compression
+// method and compressed text.
+func parseZTXT(data []byte) (string, byte, []byte, error) {
+	// +----------+----------------+--------------------+-----------------+
+	// | Keyword  | Null separator | Compression method | Compressed text |
+	// +----------+----------------+--------------------+-----------------+
+	// | 1–79     | 1 byte         | 1 byte             | n bytes         |
+	// | bytes    |                |                    |                 |
+	// +----------+----------------+--------------------+-----------------+
+	pt := bytes.IndexByte(data, NULL_SEPERATOR)
+	if pt < 0 {
+		return "", 0, nil, errors.New("zTXt chunk is missing its null separator")
+	}
+	if pt+1 >= len(data) {
+		return "", 0, nil, errors.New("zTXt chunk too short for compression method")
+	}
+	return string(data[:pt]), data[pt+1], data[pt+2:], nil
+}
+
+// inflate decompresses a zlib stream as used by zTXt and compressed iTXt
+// chunks.
+func inflate(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("inflate: %w", err)
+	}
+	defer zr.Close()
+
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("inflate: %w", err)
+	}
+	return out, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// textRecord is a keyword and its decoded text, read from a `tEXt`, `iTXt` or
+// `zTXt` chunk.
+type textRecord struct {
+	ct      string
+	keyword string
+	value   []byte
+}
+
+// eachTextRecord decodes the text chunks among `chunks` in file order, and
+// calls `fn` with each one.  Compressed text is inflated.  Iteration stops at
+// the first error, either from decoding or from `fn`.
+func eachTextRecord(data []byte, chunks []chunk, fn func(rec textRecord) error) error {
+	for _, c := range chunks {
+		rec := textRecord{ct: c.ct}
+		switch c.ct {
+		case "tEXt", "iTXt", "zTXt":
+		default:
+			continue
+		}
+
+		if !c.crcValid(data) {
+			return pngr.ErrBadCRC
+		}
+
+		var err error
+		switch c.ct {
+		case "tEXt":
+			rec.keyword, rec.value, err = parseTEXT(c.data(data))
+		case "iTXt":
+			var it *itxtRecord
+			it, err = parseITXTRecord(c.data(data))
+			if err == nil {
+				rec.keyword, rec.value = it.keyword, it.text
+				if it.compressionFlag != 0 {
+					rec.value, err = inflate(it.text)
+				}
+			}
+		case "zTXt":
+			var z []byte
+			rec.keyword, _, z, err = parseZTXT(c.data(data))
+			if err == nil {
+				rec.value, err = inflate(z)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExtractAll processes a stream of raw PNG data, and returns a map of all text
+// records found in `tEXt` and `iTXt` chunks.  If a keyword appears in more
+// than one chunk, the last one in file order wins.  A chunk whose CRC does not
+// match is an error.  Chunks with an empty keyword are invalid and skipped,
+// with a warning reported through `WithWarningHandler`; the other extractors
+// skip them likewise.
+func ExtractAll(data []byte, opts ...Option) (map[string][]byte, error) {
+	return ExtractAllLimited(data, maxChunkLength, opts...)
+}
+
+// ExtractAllLimited is like `ExtractAll` but refuses to process any file with
+// a chunk whose declared length exceeds `maxChunk` bytes.  Use this to cap the
+// memory spent on untrusted input.  The number of chunks is capped separately
+// by `WithMaxChunks`.
+func ExtractAllLimited(data []byte, maxChunk int, opts ...Option) (map[string][]byte, error) {
+	o := newOptions(opts)
+	data = o.stripBOM(data)
+	chunks, err := scanChunksLimited(data, maxChunk, o.maxChunks)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[string][]byte{}
+	for _, c := range chunks {
+		var parse func([]byte) (string, []byte, error)
+		switch c.ct {
+		case "tEXt":
+			parse = parseTEXT
+		case "iTXt":
+			parse = parseITXT
+		default:
+			continue
+		}
+
+		if !c.crcValid(data) {
+			return nil, pngr.ErrBadCRC
+		}
+		k, v, err := parse(c.data(data))
+		if err != nil {
+			return nil, err
+		}
+		if o.skipKeyword(c.ct, k) {
+			continue
+		}
+		if o.foldKeys {
+			k = foldLatin1(k)
+		}
+		ret[k] = v
+	}
+	return ret, nil
+}
+
+// extractRecords is like `ExtractAll` but also returns the records of `zTXt`
+// chunks, and inflates compressed `iTXt` text.
+func extractRecords(data []byte, opts ...Option) (map[string][]byte, error) {
+	o := newOptions(opts)
+	data = o.stripBOM(data)
+	chunks, err := o.scanChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[string][]byte{}
+	err = eachTextRecord(data, chunks, func(rec textRecord) error {
+		if !o.skipKeyword(rec.ct, rec.keyword) {
+			ret[rec.keyword] = rec.value
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ExtractTEXTFast is like `ExtractTEXT` but stops reading at the first IDAT
+// chunk, skipping the image data of large files entirely.  Text chunks placed
+// after the image data are not seen; use `ExtractTEXT` when they matter.
+// Other tools often place them there, and so does embedding with
+// `WithPlacement(GroupWithExistingText)` into such files.  The chunks read are
+// checked as by `ExtractTEXT`, and the same options apply.
+func ExtractTEXTFast(data []byte, opts ...Option) (map[string][]byte, error) {
+	o := newOptions(opts)
+	data = o.stripBOM(data)
+	chunks, err := scanChunksUntil(data, maxChunkLength, o.maxChunks, "IDAT")
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[string][]byte{}
+	for _, c := range chunks {
+		if c.ct != "tEXt" {
+			continue
+		}
+		if !c.crcValid(data) {
+			return nil, pngr.ErrBadCRC
+		}
+		o.addTEXT(ret, c.data(data))
+	}
+	return ret, nil
+}
+
+// ExtractPrefix returns the records of the `tEXt`, `iTXt` and `zTXt` chunks
+// whose keyword starts with `prefix`, with compressed text inflated.  Chunks
+// with other keywords are skipped before their text is decoded or inflated.
+func ExtractPrefix(data []byte, prefix string, opts ...Option) (map[string][]byte, error) {
+	o := newOptions(opts)
+	data = o.stripBOM(data)
+	chunks, err := o.scanChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := []chunk{}
+	for _, c := range chunks {
+		if k, ok := c.keyword(data); ok && k != "" && strings.HasPrefix(k, prefix) {
+			matched = append(matched, c)
+		}
+	}
+
+	ret := map[string][]byte{}
+	err = eachTextRecord(data, matched, func(rec textRecord) error {
+		ret[rec.keyword] = rec.value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// DuplicateKeywords returns the keywords carried by more than one `tEXt`,
+// `iTXt` or `zTXt` chunk of the PNG data, across chunk types, with the number
+// of chunks carrying each.  The map is empty, not nil, for files without
+// duplicates.  Repeated pipeline runs often leave such duplicates behind.
+func DuplicateKeywords(data []byte, opts ...Option) (map[string]int, error) {
+	o := newOptions(opts)
+	data = o.stripBOM(data)
+	chunks, err := o.scanChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+	for _, c := range chunks {
+		if k, ok := c.keyword(data); ok && k != "" {
+			counts[k]++
+		}
+	}
+	for k, n := range counts {
+		if n < 2 {
+			delete(counts, k)
+		}
+	}
+	return counts, nil
+}
+
+// ExtractByType is like `ExtractAll` but returns the records of `tEXt`, `iTXt`
+// and `zTXt` chunks in separate maps, so callers can see which chunk type each
+// keyword lives in.  Each map is non-nil, even if empty.  Within a map, the
+// last chunk in file order wins.
+func ExtractByType(data []byte, opts ...Option) (text, itxt, ztxt map[string][]byte, err error) {
+	o := newOptions(opts)
+	data = o.stripBOM(data)
+	chunks, err := o.scanChunks(data)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	text, itxt, ztxt = map[string][]byte{}, map[string][]byte{}, map[string][]byte{}
+	err = eachTextRecord(data, chunks, func(rec textRecord) error {
+		if o.skipKeyword(rec.ct, rec.keyword) {
+			return nil
+		}
+		switch rec.ct {
+		case "tEXt":
+			text[rec.keyword] = rec.value
+		case "iTXt":
+			itxt[rec.keyword] = rec.value
+		case "zTXt":
+			ztxt[rec.keyword] = rec.value
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return text, itxt, ztxt, nil
+}
+
+// GetValue returns the text stored under `key` in the PNG data, and whether it
+// was found.  Precedence follows `ExtractAll`.
+func GetValue(data []byte, key string, opts ...Option) ([]byte, bool, error) {
+	v, _, found, err := GetValueWithType(data, key, opts...)
+	return v, found, err
+}
+
+// HasKey returns true if any `tEXt`, `iTXt` or `zTXt` chunk in the PNG data
+// has keyword `key`.  Values are not decoded.  Chunks with an empty keyword
+// are skipped, as by `ExtractAll`.
+func HasKey(data []byte, key string, opts ...Option) (bool, error) {
+	o := newOptions(opts)
+	data = o.stripBOM(data)
+	chunks, err := o.scanChunks(data)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range chunks {
+		if k, ok := c.keyword(data); ok && !o.skipKeyword(c.ct, k) && k == key {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetValueWithType is like `GetValue` but also reports the type of the chunk
+// the value came from: "tEXt", "iTXt" or "zTXt".  When the key appears in
+// several chunks, of the same type or not, the last one in file order wins,
+// as it does for `ExtractAll`, and the keyword options of `ExtractAll` apply
+// too.
+func GetValueWithType(data []byte, key string, opts ...Option) (value []byte, chunkType string, found bool, err error) {
+	o := newOptions(opts)
+	data = o.stripBOM(data)
+	chunks, err := o.scanChunks(data)
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	if o.foldKeys {
+		key = foldLatin1(key)
+	}
+	err = eachTextRecord(data, chunks, func(rec textRecord) error {
+		if o.skipKeyword(rec.ct, rec.keyword) {
+			return nil
+		}
+		if o.foldKeys {
+			rec.keyword = foldLatin1(rec.keyword)
+		}
+		if rec.keyword == key {
+			value, chunkType, found = rec.value, rec.ct, true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", false, err
+	}
+	return value, chunkType, found, nil
+}
+
+// ExtractAllInto decodes the JSON value of each text record named in
+// `targets` into the pointer stored for it, or decodes it with the function
+// set by `WithUnmarshal`.  Keys missing from the PNG leave
+// their pointer untouched.  Decoding failures do not stop the remaining keys;
+// they are aggregated into a single error naming every failed key.
+func ExtractAllInto(data []byte, targets map[string]interface{}, opts ...Option) error {
+	o := newOptions(opts)
+	m, err := ExtractAll(data, opts...)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(targets))
+	for k := range targets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	errs := []string{}
+	for _, k := range keys {
+		v, ok := m[k]
+		if !ok {
+			continue
+		}
+		if err := o.decode(v, targets[k]); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", k, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to decode keys: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// TextStat describes the storage cost of a single text chunk.
+type TextStat struct {
+	Keyword          string
+	ChunkType        string
+	StoredSize       int // Size of the text as stored, possibly compressed.
+	DecompressedSize int // Size of the text once decompressed.
+}
+
+// TextStats reports the stored and decompressed size of the text carried by
+// every `tEXt`, `iTXt` and `zTXt` chunk in the PNG data, in file order.
+// Compressed chunks are inflated to measure them.
+func TextStats(data []byte, opts ...Option) ([]TextStat, error) {
+	o := newOptions(opts)
+	data = o.stripBOM(data)
+	chunks, err := o.scanChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := []TextStat{}
+	for _, c := range chunks {
+		st := TextStat{ChunkType: c.ct}
+		switch c.ct {
+		case "tEXt":
+			k, v, err := parseTEXT(c.data(data))
+			if err != nil {
+				return nil, err
+			}
+			st.Keyword, st.StoredSize, st.DecompressedSize = k, len(v), len(v)
+		case "iTXt":
+			rec, err := parseITXTRecord(c.data(data))
+			if err != nil {
+				return nil, err
+			}
+			st.Keyword, st.StoredSize, st.DecompressedSize = rec.keyword, len(rec.text), len(rec.text)
+			if rec.compressionFlag != 0 {
+				text, err := inflate(rec.text)
+				if err != nil {
+					return nil, err
+				}
+				st.DecompressedSize = len(text)
+			}
+		case "zTXt":
+			k, _, z, err := parseZTXT(c.data(data))
+			if err != nil {
+				return nil, err
+			}
+			text, err := inflate(z)
+			if err != nil {
+				return nil, err
+			}
+			st.Keyword, st.StoredSize, st.DecompressedSize = k, len(z), len(text)
+		default:
+			continue
+		}
+		stats = append(stats, st)
+	}
+	return stats, nil
+}
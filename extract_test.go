@@ -0,0 +1,488 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/sabhiram/pngr"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestExtractAll(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXT(bs, "Text", "TextValue")
+	fatalIfError(t, err)
+	out, err = EmbedITXT(out, "Intl", "IntlValue")
+	fatalIfError(t, err)
+
+	m, err := ExtractAll(out)
+	fatalIfError(t, err)
+
+	if len(m) != 2 {
+		t.Errorf("Expected 2 keys, got %d\n", len(m))
+	}
+	if string(m["Text"]) != "TextValue" || string(m["Intl"]) != "IntlValue" {
+		t.Errorf("Unexpected values extracted %v\n", m)
+	}
+}
+
+func TestExtractAllLimited(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXT(bs, "Key", "Value")
+	fatalIfError(t, err)
+
+	m, err := ExtractAllLimited(out, 1024)
+	fatalIfError(t, err)
+	if string(m["Key"]) != "Value" {
+		t.Errorf("Expected `Value`, got %s\n", m["Key"])
+	}
+
+	// Declare an enormous length for the IHDR chunk.
+	bad := append([]byte{}, out...)
+	binary.BigEndian.PutUint32(bad[8:12], 0x7fffffff)
+
+	m, err = ExtractAllLimited(bad, 1024)
+	if err == nil || !strings.Contains(err.Error(), "exceeding limit") {
+		t.Errorf("Expected limit error, got %v\n", err)
+	}
+	if m != nil {
+		t.Errorf("Expected nil map, got non-nil value\n")
+	}
+}
+
+// zTXtChunkData returns the data of a zTXt chunk holding `text` under `k`.
+func zTXtChunkData(t *testing.T, k string, text []byte) []byte {
+	buf := bytes.NewBufferString(k)
+	buf.Write([]byte{NULL_SEPERATOR, 0})
+
+	zw := zlib.NewWriter(buf)
+	_, err := zw.Write(text)
+	fatalIfError(t, err)
+	fatalIfError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestTextStats(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXT(bs, "Plain", "PlainValue")
+	fatalIfError(t, err)
+	text := bytes.Repeat([]byte("compressible "), 100)
+	out, err = EmbedChunk(out, "zTXt", zTXtChunkData(t, "Packed", text))
+	fatalIfError(t, err)
+
+	stats, err := TextStats(out)
+	fatalIfError(t, err)
+	if len(stats) != 2 {
+		t.Fatalf("Expected 2 stats, got %d\n", len(stats))
+	}
+
+	for _, st := range stats {
+		switch st.ChunkType {
+		case "zTXt":
+			if st.Keyword != "Packed" || st.DecompressedSize != len(text) {
+				t.Errorf("Unexpected zTXt stat %+v\n", st)
+			}
+			if st.StoredSize >= st.DecompressedSize {
+				t.Errorf("Expected stored < decompressed, got %+v\n", st)
+			}
+		case "tEXt":
+			if st.Keyword != "Plain" || st.StoredSize != 10 || st.DecompressedSize != 10 {
+				t.Errorf("Unexpected tEXt stat %+v\n", st)
+			}
+		default:
+			t.Errorf("Unexpected chunk type %s\n", st.ChunkType)
+		}
+	}
+}
+
+func TestExtractAllInto(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	type point struct{ X, Y int }
+	type owner struct{ Name string }
+
+	out, err := EmbedTEXT(bs, "point", point{X: 1, Y: 2})
+	fatalIfError(t, err)
+	out, err = EmbedITXT(out, "owner", owner{Name: "gopher"})
+	fatalIfError(t, err)
+	out, err = EmbedTEXT(out, "plain", "not json")
+	fatalIfError(t, err)
+
+	var (
+		p  point
+		o  owner
+		n  int
+		ms = "untouched"
+	)
+	fatalIfError(t, ExtractAllInto(out, map[string]interface{}{
+		"point":   &p,
+		"owner":   &o,
+		"missing": &ms,
+	}))
+	if p.X != 1 || p.Y != 2 || o.Name != "gopher" || ms != "untouched" {
+		t.Errorf("Unexpected decoded values %+v %+v %s\n", p, o, ms)
+	}
+
+	err = ExtractAllInto(out, map[string]interface{}{"plain": &n, "point": &p})
+	if err == nil || !strings.Contains(err.Error(), "plain") {
+		t.Errorf("Expected error naming `plain`, got %v\n", err)
+	}
+}
+
+func TestGetValueWithType(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedChunk(bs, "zTXt", zTXtChunkData(t, "Packed", []byte("PackedValue")))
+	fatalIfError(t, err)
+	out, err = EmbedITXT(out, "Intl", "IntlValue")
+	fatalIfError(t, err)
+	out, err = EmbedTEXT(out, "Text", "TextValue")
+	fatalIfError(t, err)
+
+	for _, tc := range []struct {
+		key, value, ct string
+		found          bool
+	}{
+		{key: "Text", value: "TextValue", ct: "tEXt", found: true},
+		{key: "Intl", value: "IntlValue", ct: "iTXt", found: true},
+		{key: "Packed", value: "PackedValue", ct: "zTXt", found: true},
+		{key: "Missing", found: false},
+	} {
+		v, ct, found, err := GetValueWithType(out, tc.key)
+		fatalIfError(t, err)
+		if found != tc.found || string(v) != tc.value || ct != tc.ct {
+			t.Errorf("Expected (%s, %s, %t), got (%s, %s, %t)\n",
+				tc.value, tc.ct, tc.found, v, ct, found)
+		}
+
+		v, found, err = GetValue(out, tc.key)
+		fatalIfError(t, err)
+		if found != tc.found || string(v) != tc.value {
+			t.Errorf("Expected (%s, %t), got (%s, %t)\n", tc.value, tc.found, v, found)
+		}
+	}
+
+	// "Dup" is in a tEXt chunk followed by an iTXt chunk; the latter wins.
+	dup, err := EmbedITXT(bs, "Dup", "Later")
+	fatalIfError(t, err)
+	dup, err = EmbedTEXT(dup, "Dup", "Earlier")
+	fatalIfError(t, err)
+	v, ct, _, err := GetValueWithType(dup, "Dup")
+	fatalIfError(t, err)
+	if string(v) != "Later" || ct != "iTXt" {
+		t.Errorf("Expected (Later, iTXt), got (%s, %s)\n", v, ct)
+	}
+
+	// The options of `ExtractAll` apply.
+	bom := append([]byte{0xef, 0xbb, 0xbf}, out...)
+	if _, _, err := GetValue(bom, "Text"); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+	v, found, err := GetValue(bom, "Text", WithLenientBOM())
+	fatalIfError(t, err)
+	if !found || string(v) != "TextValue" {
+		t.Errorf("Expected TextValue, got %q\n", v)
+	}
+
+	v, found, err = GetValue(out, "TEXT", WithCaseInsensitiveKeys())
+	fatalIfError(t, err)
+	if !found || string(v) != "TextValue" {
+		t.Errorf("Expected TextValue, got %q\n", v)
+	}
+
+	empty := withRawChunk(t, out, "tEXt", []byte("\x00orphan"))
+	if _, found, err := GetValue(empty, ""); err != nil || found {
+		t.Errorf("Expected the empty keyword to be skipped, got %t, %v\n", found, err)
+	}
+}
+
+func TestHasKey(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedZTXT(bs, "Key", "Value")
+	fatalIfError(t, err)
+
+	for k, exp := range map[string]bool{"Key": true, "Missing": false, "Ke": false} {
+		found, err := HasKey(out, k)
+		fatalIfError(t, err)
+		if found != exp {
+			t.Errorf("Expected %v for %s, got %v\n", exp, k, found)
+		}
+	}
+
+	bom := append([]byte{0xef, 0xbb, 0xbf}, out...)
+	found, err := HasKey(bom, "Key", WithLenientBOM())
+	fatalIfError(t, err)
+	if !found {
+		t.Errorf("Expected true for Key, got false\n")
+	}
+
+	// An empty keyword is never found, even when such a chunk exists.
+	empty := withRawChunk(t, out, "tEXt", []byte("\x00orphan"))
+	found, err = HasKey(empty, "")
+	fatalIfError(t, err)
+	if found {
+		t.Errorf("Expected false for the empty keyword, got true\n")
+	}
+}
+
+func TestExtractByType(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	text, itxt, ztxt, err := ExtractByType(bs)
+	fatalIfError(t, err)
+	if text == nil || itxt == nil || ztxt == nil {
+		t.Errorf("Expected non-nil maps for a file without text\n")
+	}
+
+	out, err := EmbedTEXT(bs, "Plain", "p")
+	fatalIfError(t, err)
+	out, err = EmbedITXT(out, "Intl", "i")
+	fatalIfError(t, err)
+	out, err = EmbedZTXT(out, "Packed", strings.Repeat("z", 200))
+	fatalIfError(t, err)
+
+	text, itxt, ztxt, err = ExtractByType(out)
+	fatalIfError(t, err)
+	if len(text) != 1 || string(text["Plain"]) != "p" {
+		t.Errorf("Unexpected tEXt records %v\n", text)
+	}
+	if len(itxt) != 1 || string(itxt["Intl"]) != "i" {
+		t.Errorf("Unexpected iTXt records %v\n", itxt)
+	}
+	if len(ztxt) != 1 || len(ztxt["Packed"]) != 200 {
+		t.Errorf("Unexpected zTXt records %v\n", ztxt)
+	}
+
+	bom := append([]byte{0xef, 0xbb, 0xbf}, out...)
+	text, _, _, err = ExtractByType(bom, WithLenientBOM())
+	fatalIfError(t, err)
+	if string(text["Plain"]) != "p" {
+		t.Errorf("Unexpected tEXt records %v\n", text)
+	}
+}
+
+func TestExtractSkipsEmptyKeyword(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXT(bs, "Key", "Value")
+	fatalIfError(t, err)
+	out = withRawChunk(t, out, "tEXt", []byte("\x00orphan"))
+	out = withRawChunk(t, out, "zTXt", zTXtChunkData(t, "", []byte("orphan")))
+
+	warnings := []string{}
+	warn := WithWarningHandler(func(msg string) { warnings = append(warnings, msg) })
+
+	for name, extract := range map[string]func([]byte, ...Option) (map[string][]byte, error){
+		"ExtractTEXT": ExtractTEXT,
+		"ExtractZTXT": ExtractZTXT,
+		"ExtractAll":  ExtractAll,
+		"ExtractByType": func(data []byte, opts ...Option) (map[string][]byte, error) {
+			text, _, ztxt, err := ExtractByType(data, opts...)
+			for k, v := range ztxt {
+				text[k] = v
+			}
+			return text, err
+		},
+	} {
+		warnings = warnings[:0]
+		m, err := extract(out, warn)
+		fatalIfError(t, err)
+		if _, ok := m[""]; ok {
+			t.Errorf("%s: expected the empty keyword to be skipped\n", name)
+		}
+		if len(warnings) == 0 {
+			t.Errorf("%s: expected a warning for the skipped chunk\n", name)
+		}
+	}
+
+	m, err := ExtractAll(out)
+	fatalIfError(t, err)
+	if len(m) != 1 || string(m["Key"]) != "Value" {
+		t.Errorf("Unexpected records %v\n", m)
+	}
+}
+
+func TestExtractPrefix(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXT(bs, "app.name", "demo")
+	fatalIfError(t, err)
+	out, err = EmbedZTXT(out, "app.notes", strings.Repeat("note ", 50))
+	fatalIfError(t, err)
+	out, err = EmbedTEXT(out, "application", "other")
+	fatalIfError(t, err)
+	out, err = EmbedITXT(out, "Author", "someone")
+	fatalIfError(t, err)
+
+	m, err := ExtractPrefix(out, "app.")
+	fatalIfError(t, err)
+	if len(m) != 2 || string(m["app.name"]) != "demo" || len(m["app.notes"]) != 250 {
+		t.Errorf("Unexpected records %v\n", m)
+	}
+
+	m, err = ExtractPrefix(out, "nothing.")
+	fatalIfError(t, err)
+	if m == nil || len(m) != 0 {
+		t.Errorf("Expected an empty map, got %v\n", m)
+	}
+
+	bom := append([]byte{0xef, 0xbb, 0xbf}, out...)
+	m, err = ExtractPrefix(bom, "app.", WithLenientBOM())
+	fatalIfError(t, err)
+	if len(m) != 2 {
+		t.Errorf("Unexpected records %v\n", m)
+	}
+}
+
+func TestExtractTEXTFast(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXT(bs, "Before", "idat")
+	fatalIfError(t, err)
+
+	// Append a tEXt chunk after the image data, as some other tools do.
+	chunks, err := scanChunks(out)
+	fatalIfError(t, err)
+	iend := chunks[indexOfChunk(chunks, "IEND")]
+	after, _ := buildChunk("tEXt", formatTEXTChunk([]byte("idat"), "After"))
+	out = append(append(append([]byte{}, out[:iend.offset]...), after...), out[iend.offset:]...)
+
+	m, err := ExtractTEXTFast(out)
+	fatalIfError(t, err)
+	if string(m["Before"]) != "idat" {
+		t.Errorf("Expected the text before IDAT, got %v\n", m)
+	}
+
+	// Text after IDAT is documented to be missed.
+	if _, ok := m["After"]; ok {
+		t.Errorf("Expected the text after IDAT to be skipped\n")
+	}
+	if full, _ := ExtractTEXT(out); string(full["After"]) != "idat" {
+		t.Errorf("Expected ExtractTEXT to see the text after IDAT\n")
+	}
+
+	// Options apply as for ExtractTEXT.
+	bom := append(append([]byte{}, utf8BOM...), out...)
+	if m, err := ExtractTEXTFast(bom, WithLenientBOM()); err != nil || string(m["Before"]) != "idat" {
+		t.Errorf("Expected the BOM skipped, got %v %v\n", m, err)
+	}
+
+	// A tEXt chunk without a separator is skipped, as by ExtractTEXT.
+	noSep := withRawChunk(t, out, "tEXt", []byte("nosep"))
+	if m, err := ExtractTEXTFast(noSep); err != nil || len(m) != 1 {
+		t.Errorf("Expected the chunk skipped, got %v %v\n", m, err)
+	}
+
+	// Negative test cases.
+	if _, err := ExtractTEXTFast(out[:20]); !errors.Is(err, ErrChunkTruncated) {
+		t.Errorf("Expected ErrChunkTruncated, got %v\n", err)
+	}
+	if _, err := ExtractTEXTFast(out, WithMaxChunks(1)); !errors.Is(err, ErrTooManyChunks) {
+		t.Errorf("Expected ErrTooManyChunks, got %v\n", err)
+	}
+	badCRC := append([]byte{}, out...)
+	badCRC[chunks[1].end()-1] ^= 0xff
+	if _, err := ExtractTEXTFast(badCRC); err != pngr.ErrBadCRC {
+		t.Errorf("Expected pngr.ErrBadCRC, got %v\n", err)
+	}
+}
+
+func TestExtractAllCaseInsensitive(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedPairs(bs, []KV{
+		{Key: "Author", Value: "first"},
+		{Key: "author", Value: "second"},
+		{Key: "Title", Value: "red"},
+	})
+	fatalIfError(t, err)
+
+	// By default keywords are case-sensitive.
+	m, err := ExtractAll(out)
+	fatalIfError(t, err)
+	if len(m) != 3 || string(m["Author"]) != "first" || string(m["author"]) != "second" {
+		t.Errorf("Expected both casings, got %v\n", m)
+	}
+
+	// Case-variants merge under the lower case key, the last one winning.
+	m, err = ExtractAll(out, WithCaseInsensitiveKeys())
+	fatalIfError(t, err)
+	if len(m) != 2 || string(m["author"]) != "second" || string(m["title"]) != "red" {
+		t.Errorf("Expected merged lower case keys, got %v\n", m)
+	}
+
+	// Latin-1 keywords fold byte by byte, without merging distinct letters.
+	out, err = EmbedPairs(bs, []KV{
+		{Key: "Caf\xc9", Value: "upper"},
+		{Key: "caf\xe9", Value: "lower"},
+		{Key: "Caf\xe8", Value: "grave"},
+		{Key: "A\xd7B", Value: "times"},
+	})
+	fatalIfError(t, err)
+	m, err = ExtractAll(out, WithCaseInsensitiveKeys())
+	fatalIfError(t, err)
+	if len(m) != 3 || string(m["caf\xe9"]) != "lower" || string(m["caf\xe8"]) != "grave" || string(m["a\xd7b"]) != "times" {
+		t.Errorf("Expected Latin-1 keys folded, got %q\n", m)
+	}
+}
+
+func TestDuplicateKeywords(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXT(bs, "Run", 1)
+	fatalIfError(t, err)
+	out, err = EmbedTEXT(out, "Unique", "once")
+	fatalIfError(t, err)
+
+	dups, err := DuplicateKeywords(out)
+	fatalIfError(t, err)
+	if dups == nil || len(dups) != 0 {
+		t.Errorf("Expected no duplicates, got %v\n", dups)
+	}
+
+	// Repeated runs, across chunk types.
+	out, err = EmbedITXT(out, "Run", 2)
+	fatalIfError(t, err)
+
+	dups, err = DuplicateKeywords(out)
+	fatalIfError(t, err)
+	if len(dups) != 1 || dups["Run"] != 2 {
+		t.Errorf("Expected Run twice, got %v\n", dups)
+	}
+
+	bom := append([]byte{0xef, 0xbb, 0xbf}, out...)
+	dups, err = DuplicateKeywords(bom, WithLenientBOM())
+	fatalIfError(t, err)
+	if len(dups) != 1 || dups["Run"] != 2 {
+		t.Errorf("Expected Run twice, got %v\n", dups)
+	}
+
+	// Negative test cases.
+	if _, err := DuplicateKeywords(out[:20]); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
@@ -0,0 +1,105 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// readFileFS is like `readFile` but reads `name` from `fsys`.
+func readFileFS(fsys fs.FS, name string) ([]byte, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, &fileReadError{path: name, err: err}
+	}
+	return data, nil
+}
+
+// EmbedTEXTInFileFS is like `EmbedTEXTInFile` but reads the PNG file `name`
+// from `fsys`, such as an `embed.FS` or `fstest.MapFS`.
+func EmbedTEXTInFileFS(fsys fs.FS, name, k string, v interface{}, opts ...Option) ([]byte, error) {
+	data, err := readFileFS(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return EmbedTEXT(data, k, v, opts...)
+}
+
+// ExtractFileTEXTFS is like `ExtractFileTEXT` but reads the PNG file `name`
+// from `fsys`.
+func ExtractFileTEXTFS(fsys fs.FS, name string, opts ...Option) (map[string][]byte, error) {
+	data, err := readFileFS(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return ExtractTEXT(data, opts...)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// WriteFile reads the png file at `src`, applies `edit` to its data, and
+// writes the result to `dst` with the permissions of `src`.  Under
+// `WithPreserveMtime`, the modification time of `src` is carried over to
+// `dst`.  The result is written to a temporary file next to `dst` which then
+// replaces it, so `dst` is never left partially written.  Nothing is written
+// if `edit` fails.
+func WriteFile(src, dst string, edit func(data []byte) ([]byte, error), opts ...Option) error {
+	o := newOptions(opts)
+
+	fi, err := os.Stat(src)
+	if err != nil {
+		return &fileReadError{path: src, err: err}
+	}
+	data, err := readFile(src)
+	if err != nil {
+		return err
+	}
+
+	out, err := edit(data)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*")
+	if err != nil {
+		return err
+	}
+	if err := writeTemp(tmp, out, fi, o.preserveMtime); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// writeTemp writes `data` to the temporary file `tmp` and closes it, then
+// gives it the permissions of `fi`, and its modification time if `mtime` is
+// set.
+func writeTemp(tmp *os.File, data []byte, fi os.FileInfo, mtime bool) error {
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), fi.Mode().Perm()); err != nil {
+		return err
+	}
+	if mtime {
+		return os.Chtimes(tmp.Name(), fi.ModTime(), fi.ModTime())
+	}
+	return nil
+}
+
+// EditFile is like `WriteFile` but writes the result back to `fp` itself.
+func EditFile(fp string, edit func(data []byte) ([]byte, error), opts ...Option) error {
+	return WriteFile(fp, fp, edit, opts...)
+}
@@ -0,0 +1,129 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// embedKey returns an edit embedding `Key`: `Value`.
+func embedKey(data []byte) ([]byte, error) {
+	return EmbedTEXT(data, "Key", "Value")
+}
+
+func TestWriteFilePreserveMtime(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.png")
+	fatalIfError(t, os.WriteFile(src, bs, 0644))
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	fatalIfError(t, os.Chtimes(src, mtime, mtime))
+
+	dst := filepath.Join(dir, "dst.png")
+	fatalIfError(t, WriteFile(src, dst, embedKey, WithPreserveMtime()))
+
+	fi, err := os.Stat(dst)
+	fatalIfError(t, err)
+	if !fi.ModTime().Equal(mtime) {
+		t.Errorf("Expected mtime %s, got %s\n", mtime, fi.ModTime())
+	}
+	m, err := ExtractFileTEXT(dst)
+	fatalIfError(t, err)
+	if string(m["Key"]) != "Value" {
+		t.Errorf("Expected Value, got %s\n", m["Key"])
+	}
+
+	// Without the option, the mtime is that of the write.
+	fatalIfError(t, EditFile(src, embedKey))
+	fi, err = os.Stat(src)
+	fatalIfError(t, err)
+	if fi.ModTime().Equal(mtime) {
+		t.Errorf("Expected a fresh mtime without WithPreserveMtime\n")
+	}
+
+	// Negative test cases.
+	missing := filepath.Join(dir, "missing.png")
+	if err := EditFile(missing, embedKey); !errors.Is(err, ErrFileRead) {
+		t.Errorf("Expected ErrFileRead, got %v\n", err)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.png")
+	fatalIfError(t, os.WriteFile(src, bs, 0600))
+
+	// An existing `dst` takes the permissions of `src`.
+	dst := filepath.Join(dir, "dst.png")
+	fatalIfError(t, os.WriteFile(dst, nil, 0644))
+	fatalIfError(t, WriteFile(src, dst, embedKey))
+	fi, err := os.Stat(dst)
+	fatalIfError(t, err)
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %o\n", fi.Mode().Perm())
+	}
+
+	// A failed edit leaves the original untouched.
+	failed := errors.New("edit failed")
+	err = EditFile(src, func([]byte) ([]byte, error) { return nil, failed })
+	if !errors.Is(err, failed) {
+		t.Errorf("Expected the edit error, got %v\n", err)
+	}
+	act, err := ioutil.ReadFile(src)
+	fatalIfError(t, err)
+	if string(act) != string(bs) {
+		t.Errorf("Expected the original file to survive a failed edit\n")
+	}
+
+	// No temporary files are left behind.
+	entries, err := os.ReadDir(dir)
+	fatalIfError(t, err)
+	if len(entries) != 2 {
+		t.Errorf("Expected 2 files, got %d\n", len(entries))
+	}
+}
+
+func TestFileHelpersFS(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+	withKey, err := EmbedTEXT(bs, "Key", "Value")
+	fatalIfError(t, err)
+
+	fsys := fstest.MapFS{
+		"red.png":       {Data: bs},
+		"sub/keyed.png": {Data: withKey},
+	}
+
+	m, err := ExtractFileTEXTFS(fsys, "sub/keyed.png")
+	fatalIfError(t, err)
+	if string(m["Key"]) != "Value" {
+		t.Errorf("Expected Value, got %s\n", m["Key"])
+	}
+
+	out, err := EmbedTEXTInFileFS(fsys, "red.png", "Key", "Value")
+	fatalIfError(t, err)
+	if string(out) != string(withKey) {
+		t.Errorf("Expected the output of EmbedTEXT\n")
+	}
+
+	// Negative test cases.
+	if _, err := ExtractFileTEXTFS(fsys, "missing.png"); !errors.Is(err, ErrFileRead) {
+		t.Errorf("Expected ErrFileRead, got %v\n", err)
+	}
+	if _, err := EmbedTEXTInFileFS(fsys, "missing.png", "Key", "Value"); !errors.Is(err, ErrFileRead) {
+		t.Errorf("Expected ErrFileRead, got %v\n", err)
+	}
+}
@@ -0,0 +1,255 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Color types as recorded in the IHDR chunk.
+const (
+	ColorGrayscale      = 0
+	ColorTruecolor      = 2
+	ColorIndexed        = 3
+	ColorGrayscaleAlpha = 4
+	ColorTruecolorAlpha = 6
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Header holds the fields of a png image's IHDR chunk.
+type Header struct {
+	Width             uint32
+	Height            uint32
+	BitDepth          uint8
+	ColorType         uint8
+	CompressionMethod uint8
+	FilterMethod      uint8
+	InterlaceMethod   uint8
+}
+
+// channels returns the number of samples per pixel for the header's color
+// type, or 0 if the color type is invalid.
+func (h *Header) channels() int {
+	switch h.ColorType {
+	case ColorGrayscale, ColorIndexed:
+		return 1
+	case ColorGrayscaleAlpha:
+		return 2
+	case ColorTruecolor:
+		return 3
+	case ColorTruecolorAlpha:
+		return 4
+	}
+	return 0
+}
+
+// sampleDepth returns the bit depth of the image's samples, which for indexed
+// images is that of the palette entries rather than of the indices.
+func (h *Header) sampleDepth() int {
+	if h.ColorType == ColorIndexed {
+		return 8
+	}
+	return int(h.BitDepth)
+}
+
+// GetHeader parses the IHDR chunk of the PNG data.
+func GetHeader(data []byte) (*Header, error) {
+	chunks, err := scanChunks(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 || chunks[0].ct != "IHDR" {
+		return nil, ErrIHDRNotFirst
+	}
+	d := chunks[0].data(data)
+	if len(d) != 13 {
+		return nil, fmt.Errorf("IHDR chunk has length %d, expected 13", len(d))
+	}
+
+	h := &Header{
+		Width:             binary.BigEndian.Uint32(d[0:4]),
+		Height:            binary.BigEndian.Uint32(d[4:8]),
+		BitDepth:          d[8],
+		ColorType:         d[9],
+		CompressionMethod: d[10],
+		FilterMethod:      d[11],
+		InterlaceMethod:   d[12],
+	}
+	if h.channels() == 0 {
+		return nil, fmt.Errorf("IHDR has invalid color type %d", h.ColorType)
+	}
+	return h, nil
+}
+
+// IsInterlaced returns true if the IHDR chunk of the PNG data selects Adam7
+// interlacing.  Placement of text chunks is unaffected, but some downstream
+// tools treat interlaced images differently.
+func IsInterlaced(data []byte) (bool, error) {
+	h, err := GetHeader(data)
+	if err != nil {
+		return false, err
+	}
+	return h.InterlaceMethod == 1, nil
+}
+
+// headerAndChunk returns the parsed header of the png data, along with the
+// data of its first chunk of type `ct`, or nil if there is none.
+func headerAndChunk(data []byte, ct string) (*Header, []byte, error) {
+	h, err := GetHeader(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	chunks, err := scanChunks(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	i := indexOfChunk(chunks, ct)
+	if i < 0 {
+		return nil, nil, fmt.Errorf("missing %s chunk", ct)
+	}
+	return h, chunks[i].data(data), nil
+}
+
+// uint16Samples splits `d` into 2 byte samples, checking that there are `n` of
+// them and that each fits in `depth` bits.
+func uint16Samples(ct string, d []byte, n, depth int) ([]uint16, error) {
+	if len(d) != 2*n {
+		return nil, fmt.Errorf("%s chunk has length %d, expected %d", ct, len(d), 2*n)
+	}
+	ret := make([]uint16, n)
+	for i := range ret {
+		ret[i] = binary.BigEndian.Uint16(d[2*i:])
+		if int(ret[i]) >= 1<<depth {
+			return nil, fmt.Errorf("%s sample %d exceeds bit depth %d", ct, ret[i], depth)
+		}
+	}
+	return ret, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ExtractBKGD returns the background color recorded in the bKGD chunk of the
+// PNG data, interpreted by the IHDR color type and bit depth: a palette index
+// for indexed images, one gray sample for grayscale images, and red, green and
+// blue samples for truecolor images.
+func ExtractBKGD(data []byte) ([]uint16, error) {
+	h, d, err := headerAndChunk(data, "bKGD")
+	if err != nil {
+		return nil, err
+	}
+
+	switch h.ColorType {
+	case ColorIndexed:
+		if len(d) != 1 {
+			return nil, fmt.Errorf("bKGD chunk has length %d, expected 1", len(d))
+		}
+		return []uint16{uint16(d[0])}, nil
+	case ColorGrayscale, ColorGrayscaleAlpha:
+		return uint16Samples("bKGD", d, 1, int(h.BitDepth))
+	default:
+		return uint16Samples("bKGD", d, 3, int(h.BitDepth))
+	}
+}
+
+// ExtractSBIT returns the significant bits per channel recorded in the sBIT
+// chunk of the PNG data.  Each must lie between 1 and the sample depth.
+func ExtractSBIT(data []byte) ([]uint8, error) {
+	h, d, err := headerAndChunk(data, "sBIT")
+	if err != nil {
+		return nil, err
+	}
+
+	n := h.channels()
+	if h.ColorType == ColorIndexed {
+		n = 3
+	}
+	if len(d) != n {
+		return nil, fmt.Errorf("sBIT chunk has length %d, expected %d", len(d), n)
+	}
+	for _, b := range d {
+		if b == 0 || int(b) > h.sampleDepth() {
+			return nil, fmt.Errorf("sBIT value %d outside 1 to %d", b, h.sampleDepth())
+		}
+	}
+	return append([]uint8{}, d...), nil
+}
+
+// ExtractTRNS returns the transparency recorded in the tRNS chunk of the PNG
+// data: one alpha value per palette entry for indexed images, the transparent
+// gray sample for grayscale images, and the transparent red, green and blue
+// samples for truecolor images.  Images with an alpha channel may not carry a
+// tRNS chunk.
+func ExtractTRNS(data []byte) ([]uint16, error) {
+	h, d, err := headerAndChunk(data, "tRNS")
+	if err != nil {
+		return nil, err
+	}
+
+	switch h.ColorType {
+	case ColorIndexed:
+		ret := make([]uint16, len(d))
+		for i, a := range d {
+			ret[i] = uint16(a)
+		}
+		return ret, nil
+	case ColorGrayscale:
+		return uint16Samples("tRNS", d, 1, int(h.BitDepth))
+	case ColorTruecolor:
+		return uint16Samples("tRNS", d, 3, int(h.BitDepth))
+	default:
+		return nil, fmt.Errorf("tRNS chunk not allowed for color type %d", h.ColorType)
+	}
+}
+
+// PNGProfile summarizes the features a png file declares through its chunks.
+type PNGProfile struct {
+	Header *Header
+
+	// ColorManaged is set if the file carries an iCCP, sRGB or gAMA chunk.
+	ColorManaged bool
+	// Transparent is set if the file has a tRNS chunk or an alpha channel.
+	Transparent bool
+	// PhysicalDimensions is set if the file carries a pHYs chunk.
+	PhysicalDimensions bool
+	// HasText is set if the file carries a tEXt, zTXt or iTXt chunk.
+	HasText bool
+	// Animated is set if the file is an APNG, declared by an acTL chunk.
+	Animated bool
+}
+
+// Profile surveys the chunks of the PNG data and reports which features it
+// declares, as a one call overview for asset management tools.
+func Profile(data []byte) (PNGProfile, error) {
+	h, err := GetHeader(data)
+	if err != nil {
+		return PNGProfile{}, err
+	}
+	chunks, err := scanChunks(data)
+	if err != nil {
+		return PNGProfile{}, err
+	}
+
+	p := PNGProfile{
+		Header:      h,
+		Transparent: h.ColorType == ColorGrayscaleAlpha || h.ColorType == ColorTruecolorAlpha,
+	}
+	for _, c := range chunks {
+		switch c.ct {
+		case "iCCP", "sRGB", "gAMA":
+			p.ColorManaged = true
+		case "tRNS":
+			p.Transparent = true
+		case "pHYs":
+			p.PhysicalDimensions = true
+		case "tEXt", "zTXt", "iTXt":
+			p.HasText = true
+		case "acTL":
+			p.Animated = true
+		}
+	}
+	return p, nil
+}
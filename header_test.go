@@ -0,0 +1,170 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// truecolor16PNG returns an encoded, opaque 4x4 16-bit truecolor png image.
+func truecolor16PNG(t *testing.T) []byte {
+	img := image.NewRGBA64(image.Rect(0, 0, 4, 4))
+	for i := 0; i < 16; i++ {
+		img.SetRGBA64(i%4, i/4, color.RGBA64{0x1234, 0x5678, 0x9abc, 0xffff})
+	}
+
+	buf := &bytes.Buffer{}
+	fatalIfError(t, png.Encode(buf, img))
+	return buf.Bytes()
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestGetHeader(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	h, err := GetHeader(bs)
+	fatalIfError(t, err)
+	if h.Width != 16 || h.Height != 16 || h.BitDepth != 8 || h.ColorType != ColorTruecolor {
+		t.Errorf("Unexpected header %+v\n", h)
+	}
+
+	h, err = GetHeader(truecolor16PNG(t))
+	fatalIfError(t, err)
+	if h.BitDepth != 16 || h.ColorType != ColorTruecolor {
+		t.Errorf("Unexpected header %+v\n", h)
+	}
+}
+
+func TestExtractBKGD16Bit(t *testing.T) {
+	tc16 := truecolor16PNG(t)
+
+	out, err := EmbedChunk(tc16, "bKGD", []byte{0x12, 0x34, 0xab, 0xcd, 0xff, 0xff})
+	fatalIfError(t, err)
+	bg, err := ExtractBKGD(out)
+	fatalIfError(t, err)
+	if len(bg) != 3 || bg[0] != 0x1234 || bg[1] != 0xabcd || bg[2] != 0xffff {
+		t.Errorf("Expected three 2-byte samples, got %v\n", bg)
+	}
+
+	// A palette index is the wrong length for a truecolor image.
+	out, err = EmbedChunk(tc16, "bKGD", []byte{1})
+	fatalIfError(t, err)
+	if _, err := ExtractBKGD(out); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+
+	// 16-bit samples do not fit an 8-bit image.
+	red, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+	out, err = EmbedChunk(red, "bKGD", []byte{0x12, 0x34, 0, 0, 0, 0})
+	fatalIfError(t, err)
+	if _, err := ExtractBKGD(out); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
+
+func TestExtractSBITAndTRNS(t *testing.T) {
+	tc16 := truecolor16PNG(t)
+
+	out, err := EmbedChunk(tc16, "sBIT", []byte{12, 12, 16})
+	fatalIfError(t, err)
+	sb, err := ExtractSBIT(out)
+	fatalIfError(t, err)
+	if len(sb) != 3 || sb[2] != 16 {
+		t.Errorf("Unexpected sBIT %v\n", sb)
+	}
+
+	out, err = EmbedChunk(tc16, "sBIT", []byte{12, 12, 17})
+	fatalIfError(t, err)
+	if _, err := ExtractSBIT(out); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+
+	out, err = EmbedChunk(tc16, "tRNS", []byte{0, 1, 0, 2, 0, 3})
+	fatalIfError(t, err)
+	tr, err := ExtractTRNS(out)
+	fatalIfError(t, err)
+	if len(tr) != 3 || tr[0] != 1 || tr[2] != 3 {
+		t.Errorf("Unexpected tRNS %v\n", tr)
+	}
+
+	out, err = EmbedChunk(palettePNG(t), "tRNS", []byte{0, 128})
+	fatalIfError(t, err)
+	tr, err = ExtractTRNS(out)
+	fatalIfError(t, err)
+	if len(tr) != 2 || tr[1] != 128 {
+		t.Errorf("Unexpected tRNS %v\n", tr)
+	}
+}
+
+func TestIsInterlaced(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	// Flip the IHDR interlace method of the fixture and repair its CRC.
+	adam7 := append([]byte{}, bs...)
+	adam7[8+8+12] = 1
+	adam7, err = FixCRCs(adam7)
+	fatalIfError(t, err)
+
+	for _, tc := range []struct {
+		data []byte
+		exp  bool
+	}{
+		{data: bs, exp: false},
+		{data: adam7, exp: true},
+	} {
+		got, err := IsInterlaced(tc.data)
+		fatalIfError(t, err)
+		if got != tc.exp {
+			t.Errorf("Expected %v, got %v\n", tc.exp, got)
+		}
+	}
+
+	if _, err := IsInterlaced([]byte{1, 2, 3}); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
+
+func TestProfile(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	p, err := Profile(bs)
+	fatalIfError(t, err)
+	if p != (PNGProfile{Header: p.Header}) || p.Header.Width != 16 {
+		t.Errorf("Expected a bare profile, got %+v\n", p)
+	}
+
+	out, err := EmbedChunk(bs, "sRGB", []byte{0})
+	fatalIfError(t, err)
+	out, err = EmbedTEXT(out, "Title", "red")
+	fatalIfError(t, err)
+
+	p, err = Profile(out)
+	fatalIfError(t, err)
+	exp := PNGProfile{Header: p.Header, ColorManaged: true, HasText: true}
+	if p != exp {
+		t.Errorf("Expected %+v, got %+v\n", exp, p)
+	}
+
+	p, err = Profile(apngPNG(t))
+	fatalIfError(t, err)
+	if !p.Animated || p.Transparent || p.PhysicalDimensions {
+		t.Errorf("Expected an animated profile, got %+v\n", p)
+	}
+
+	// Negative test cases.
+	if _, err := Profile(bs[:20]); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
@@ -0,0 +1,48 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// EmbedFromImage encodes `img` as a png with the default encoder and embeds
+// every key-value pair of `kv` as with `EmbedMulti`.
+func EmbedFromImage(img image.Image, kv map[string]interface{}, opts ...Option) ([]byte, error) {
+	return EmbedFromImageWriter(img, nil, kv, opts...)
+}
+
+// EmbedFromImageWriter is like `EmbedFromImage` but encodes `img` with `enc`,
+// so the caller controls settings such as the compression level.  A nil `enc`
+// uses the default encoder.
+func EmbedFromImageWriter(img image.Image, enc *png.Encoder, kv map[string]interface{}, opts ...Option) ([]byte, error) {
+	if enc == nil {
+		enc = &png.Encoder{}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := enc.Encode(buf, img); err != nil {
+		return nil, err
+	}
+	return EmbedMulti(buf.Bytes(), kv, opts...)
+}
+
+// EmbedTEXTChecked is like `EmbedTEXT` but then decodes the header of the
+// result with `image/png` to confirm it is still a valid png.  If it is not,
+// an error is returned and no data, so a broken file is never written in its
+// place.
+func EmbedTEXTChecked(data []byte, k string, v interface{}, opts ...Option) ([]byte, error) {
+	out, err := EmbedTEXT(data, k, v, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := png.DecodeConfig(bytes.NewReader(out)); err != nil {
+		return nil, fmt.Errorf("embedding produced an undecodable png: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,79 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestEmbedFromImageWriter(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for i := 0; i < 64*64; i++ {
+		img.Set(i%64, i/64, color.RGBA{uint8(i), uint8(i / 64), 0, 255})
+	}
+	kv := map[string]interface{}{"Key0": "Value0", "Key1": 1}
+
+	best, err := EmbedFromImageWriter(img, &png.Encoder{CompressionLevel: png.BestCompression}, kv)
+	fatalIfError(t, err)
+	none, err := EmbedFromImageWriter(img, &png.Encoder{CompressionLevel: png.NoCompression}, kv)
+	fatalIfError(t, err)
+	if len(best) >= len(none) {
+		t.Errorf("Expected best compression to be smaller: %d >= %d\n", len(best), len(none))
+	}
+
+	for _, out := range [][]byte{best, none} {
+		m, err := ExtractTEXT(out)
+		fatalIfError(t, err)
+		if string(m["Key0"]) != "Value0" || string(m["Key1"]) != "1" {
+			t.Errorf("Unexpected metadata %v\n", m)
+		}
+
+		decoded, err := png.Decode(bytes.NewReader(out))
+		fatalIfError(t, err)
+		if decoded.Bounds() != img.Bounds() {
+			t.Errorf("Unexpected bounds %v\n", decoded.Bounds())
+		}
+	}
+
+	def, err := EmbedFromImage(img, kv)
+	fatalIfError(t, err)
+	if _, found, _ := GetValue(def, "Key0"); !found {
+		t.Errorf("Expected Key0 in default encoding\n")
+	}
+}
+
+func TestEmbedTEXTChecked(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	for _, data := range [][]byte{bs, palettePNG(t), truecolor16PNG(t)} {
+		exp, err := EmbedTEXT(data, "Key", "Value")
+		fatalIfError(t, err)
+		out, err := EmbedTEXTChecked(data, "Key", "Value")
+		fatalIfError(t, err)
+		if !bytes.Equal(out, exp) {
+			t.Errorf("Expected checked output to match unchecked output\n")
+		}
+	}
+
+	// An IHDR with an invalid color type scans fine but does not decode.
+	bad := append([]byte{}, bs...)
+	bad[8+8+9] = 5
+	bad, err = FixCRCs(bad)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXTChecked(bad, "Key", "Value")
+	if err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+	if out != nil {
+		t.Errorf("Expected no data on failure\n")
+	}
+}
@@ -0,0 +1,262 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sabhiram/pngr"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Compression flags of iTXt chunks.
+const (
+	CompressionFlagOff = 0 // The text is stored as is.
+	CompressionFlagOn  = 1 // The text is compressed with the given method.
+)
+
+// Compression methods of iTXt and zTXt chunks.  The png specification defines
+// only zlib; uncompressed iTXt chunks record the same method byte.
+const (
+	CompressionNone = 0
+	CompressionZlib = 0
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// validateITXTCompression rejects compression flag and method combinations
+// other than those named by the `Compression*` constants.
+func validateITXTCompression(flag, method int) error {
+	if flag != CompressionFlagOff && flag != CompressionFlagOn {
+		return fmt.Errorf("invalid iTXt compression flag (%d)", flag)
+	}
+	if method != CompressionZlib {
+		return fmt.Errorf("unsupported iTXt compression method (%d)", method)
+	}
+	return nil
+}
+
+// EmbedITXTFull is like `EmbedITXT` but sets every field of the iTXt chunk:
+// with `flag` set to `CompressionFlagOn`, the value is compressed with
+// `method`, and the chunk records the RFC 3066 `languageTag` and the UTF-8
+// `translatedKeyword`, either of which may be empty.  Only the combinations
+// named by the `Compression*` constants are accepted, and the language tag
+// and translated keyword are validated, as a null byte in either would shift
+// the fields which follow.
+func EmbedITXTFull(data []byte, k string, v interface{}, flag, method int, languageTag, translatedKeyword string, opts ...Option) ([]byte, error) {
+	if err := validateITXTCompression(flag, method); err != nil {
+		return nil, err
+	}
+	if err := validateLanguageTag(languageTag); err != nil {
+		return nil, err
+	}
+	if err := validateTranslatedKeyword(translatedKeyword); err != nil {
+		return nil, err
+	}
+
+	o := newOptions(opts)
+	k, err := o.keyword(k, true)
+	if err != nil {
+		return nil, err
+	}
+	data = o.stripBOM(data)
+
+	val, err := o.serialize(v)
+	if err != nil {
+		return nil, err
+	}
+	if flag == CompressionFlagOn {
+		val = deflate(val)
+	}
+
+	pngChunk, _ := buildChunk(`iTXt`, formatITXTChunk(val, k, flag, method, languageTag, translatedKeyword))
+	return embedWithOptions(data, pngChunk, k, v, o)
+}
+
+// ITXTRecord holds every field of an iTXt chunk.
+type ITXTRecord struct {
+	Keyword           string
+	LanguageTag       string // RFC 3066 language tag, or empty.
+	TranslatedKeyword string // UTF-8 translation of `Keyword`, or empty.
+	Compressed        bool   // Whether the text was stored compressed.
+	Text              []byte // The text, inflated if it was compressed.
+}
+
+// ExtractITXTFull returns every iTXt chunk of the PNG data in file order, with
+// its language tag and translated keyword, and its text inflated.  Unlike
+// `ExtractITXT` it keeps all chunks sharing a keyword, as localized files
+// carry one per language.
+func ExtractITXTFull(data []byte, opts ...Option) ([]ITXTRecord, error) {
+	o := newOptions(opts)
+	data = o.stripBOM(data)
+	chunks, err := scanChunksLimited(data, maxChunkLength, o.maxChunks)
+	if err != nil {
+		return nil, err
+	}
+
+	recs := []ITXTRecord{}
+	for _, c := range chunks {
+		if c.ct != "iTXt" {
+			continue
+		}
+		if !c.crcValid(data) {
+			return nil, pngr.ErrBadCRC
+		}
+
+		it, err := parseITXTRecord(c.data(data))
+		if err != nil {
+			return nil, err
+		}
+		rec := ITXTRecord{
+			Keyword:           it.keyword,
+			LanguageTag:       it.languageTag,
+			TranslatedKeyword: it.translatedKeyword,
+			Compressed:        it.compressionFlag != 0,
+			Text:              it.text,
+		}
+		if rec.Compressed {
+			if rec.Text, err = inflate(it.text); err != nil {
+				return nil, err
+			}
+		} else if rec.Text, err = o.utf8.check(it.keyword, it.text); err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+// ExtractITXTLocalized is like `ExtractITXT` but keeps the localized variants
+// of each keyword, mapping keyword to language tag to text.  Chunks without a
+// language tag are found under the empty tag.  If a keyword and language tag
+// pair appears more than once, the last one in file order wins.
+func ExtractITXTLocalized(data []byte, opts ...Option) (map[string]map[string][]byte, error) {
+	recs, err := ExtractITXTFull(data, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[string]map[string][]byte{}
+	for _, rec := range recs {
+		if rec.Keyword == "" {
+			continue
+		}
+		if ret[rec.Keyword] == nil {
+			ret[rec.Keyword] = map[string][]byte{}
+		}
+		ret[rec.Keyword][rec.LanguageTag] = rec.Text
+	}
+	return ret, nil
+}
+
+// validateLanguageTag checks that `tag` is empty, or an RFC 3066 language tag:
+// subtags of 1 to 8 ASCII letters or digits, separated by hyphens, the first
+// of which holds letters only.
+func validateLanguageTag(tag string) error {
+	if tag == "" {
+		return nil
+	}
+	for i, sub := range strings.Split(tag, "-") {
+		if len(sub) < 1 || len(sub) > 8 {
+			return fmt.Errorf("invalid language tag (%s)", tag)
+		}
+		for _, r := range sub {
+			alpha := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+			digit := r >= '0' && r <= '9'
+			if !alpha && !(digit && i > 0) {
+				return fmt.Errorf("invalid language tag (%s)", tag)
+			}
+		}
+	}
+	return nil
+}
+
+// validateTranslatedKeyword checks that the translated keyword `tk` of an iTXt
+// chunk is valid UTF-8 without a null byte, which would end it early.
+func validateTranslatedKeyword(tk string) error {
+	if strings.IndexByte(tk, NULL_SEPERATOR) >= 0 {
+		return fmt.Errorf("translated keyword (%q) contains a null byte", tk)
+	}
+	if !utf8.ValidString(tk) {
+		return fmt.Errorf("translated keyword (%q) is not valid UTF-8", tk)
+	}
+	return nil
+}
+
+// EmbedITXTLocalized writes one uncompressed iTXt chunk per entry of
+// `byLang`, each holding the keyword `k`, the entry's language tag and its
+// UTF-8 text, in language tag order.  Language tags must be RFC 3066 tags, or
+// empty for an unknown language.  Tags are case-insensitive, so tags which
+// differ only in case are rejected as duplicates.  An empty `byLang` leaves
+// the image unchanged, though it is still validated.  Read the variants back
+// with `ExtractITXTLocalized`.
+func EmbedITXTLocalized(data []byte, k string, byLang map[string]string, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	k, err := o.keyword(k, true)
+	if err != nil {
+		return nil, err
+	}
+	data = o.stripBOM(data)
+	if err := checkSignature(data); err != nil {
+		return nil, err
+	}
+
+	tags := make([]string, 0, len(byLang))
+	seen := map[string]string{}
+	for tag, text := range byLang {
+		if err := validateLanguageTag(tag); err != nil {
+			return nil, err
+		}
+		if prev, ok := seen[strings.ToLower(tag)]; ok {
+			return nil, fmt.Errorf("duplicate language tags (%s) and (%s)", prev, tag)
+		}
+		if !utf8.ValidString(text) {
+			return nil, fmt.Errorf("text for language tag (%s) is not valid UTF-8", tag)
+		}
+		seen[strings.ToLower(tag)] = tag
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	block := []byte{}
+	for _, tag := range tags {
+		text := o.newlines.normalize([]byte(byLang[tag]))
+		pngChunk, _ := buildChunk(`iTXt`, formatITXTChunk(text, k, CompressionFlagOff, CompressionNone, tag, ""))
+		block = append(block, pngChunk...)
+	}
+	if len(block) == 0 {
+		return data, nil
+	}
+
+	out, err := o.embed(data, block)
+	if err != nil {
+		return nil, err
+	}
+	return o.postEmbed(out, nil)
+}
+
+// LanguageTags returns the distinct, non-empty language tags of the iTXt
+// chunks of the PNG data, sorted, so tools can discover which locales a file
+// carries metadata for.  Tags are case-insensitive, so tags which differ only
+// in case are listed once, as first spelt in file order.
+func LanguageTags(data []byte) ([]string, error) {
+	recs, err := ExtractITXTFull(data)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	tags := []string{}
+	for _, rec := range recs {
+		if tag := strings.ToLower(rec.LanguageTag); tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, rec.LanguageTag)
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
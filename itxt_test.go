@@ -0,0 +1,196 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestExtractITXTFull(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedChunk(bs, "iTXt", formatITXTChunk([]byte("赤い画像"), "Title", 0, 0, "ja", "タイトル"))
+	fatalIfError(t, err)
+	out, err = EmbedITXTCompressed(out, "Comment", strings.Repeat("red ", 100))
+	fatalIfError(t, err)
+
+	recs, err := ExtractITXTFull(out)
+	fatalIfError(t, err)
+	if len(recs) != 2 {
+		t.Fatalf("Expected 2 records, got %v\n", recs)
+	}
+
+	for _, rec := range recs {
+		switch rec.Keyword {
+		case "Title":
+			if rec.LanguageTag != "ja" || rec.TranslatedKeyword != "タイトル" ||
+				string(rec.Text) != "赤い画像" || rec.Compressed {
+				t.Errorf("Unexpected record %+v\n", rec)
+			}
+		case "Comment":
+			if !rec.Compressed || len(rec.Text) != 400 {
+				t.Errorf("Expected inflated text, got %+v\n", rec)
+			}
+		}
+	}
+}
+
+func TestEmbedITXTFull(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	// Negative test cases.
+	for _, fm := range [][2]int{{2, CompressionZlib}, {CompressionFlagOn, 1}, {-1, 0}} {
+		if _, err := EmbedITXTFull(bs, "Key", "v", fm[0], fm[1], "", ""); err == nil {
+			t.Errorf("Expected error for flag %d method %d, got nil!\n", fm[0], fm[1])
+		}
+	}
+	for _, lt := range [][2]string{{"e\x00n", ""}, {"not a tag", ""}, {"en", "Ti\x00tle"}, {"en", "\xff"}} {
+		if _, err := EmbedITXTFull(bs, "Key", "v", CompressionFlagOff, CompressionNone, lt[0], lt[1]); err == nil {
+			t.Errorf("Expected error for tag %q translated keyword %q, got nil!\n", lt[0], lt[1])
+		}
+	}
+
+	// Positive test cases.
+	out, err := EmbedITXTFull(bs, "Title", "Red", CompressionFlagOff, CompressionNone, "en", "Title")
+	fatalIfError(t, err)
+	out, err = EmbedITXTFull(out, "Notes", strings.Repeat("red ", 50), CompressionFlagOn, CompressionZlib, "en-GB", "")
+	fatalIfError(t, err)
+
+	recs, err := ExtractITXTFull(out)
+	fatalIfError(t, err)
+	if len(recs) != 2 {
+		t.Fatalf("Expected 2 records, got %v\n", recs)
+	}
+	notes, title := recs[0], recs[1]
+	if !notes.Compressed || notes.LanguageTag != "en-GB" || len(notes.Text) != 200 {
+		t.Errorf("Unexpected record %+v\n", notes)
+	}
+	if title.Compressed || title.LanguageTag != "en" || title.TranslatedKeyword != "Title" || string(title.Text) != "Red" {
+		t.Errorf("Unexpected record %+v\n", title)
+	}
+}
+
+func TestExtractITXTLocalized(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedITXTFull(bs, "Title", "Red image", CompressionFlagOff, CompressionNone, "en", "Title")
+	fatalIfError(t, err)
+	out, err = EmbedITXTFull(out, "Title", "Image rouge", CompressionFlagOff, CompressionNone, "fr", "Titre")
+	fatalIfError(t, err)
+	out, err = EmbedITXT(out, "Author", "someone")
+	fatalIfError(t, err)
+
+	m, err := ExtractITXTLocalized(out)
+	fatalIfError(t, err)
+	if len(m) != 2 || len(m["Title"]) != 2 {
+		t.Fatalf("Expected 2 keywords and 2 titles, got %v\n", m)
+	}
+	if string(m["Title"]["en"]) != "Red image" || string(m["Title"]["fr"]) != "Image rouge" {
+		t.Errorf("Expected both localizations, got %v\n", m["Title"])
+	}
+	if string(m["Author"][""]) != "someone" {
+		t.Errorf("Expected the untagged Author under the empty tag, got %v\n", m["Author"])
+	}
+
+	// ExtractITXT collapses the localizations.
+	flat, err := ExtractITXT(out)
+	fatalIfError(t, err)
+	if len(flat) != 2 {
+		t.Errorf("Expected 2 keywords, got %v\n", flat)
+	}
+}
+
+func TestEmbedITXTLocalized(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedITXTLocalized(bs, "Caption", map[string]string{
+		"en-GB": "A red square",
+		"fr":    "Un carré rouge",
+	})
+	fatalIfError(t, err)
+
+	m, err := ExtractITXTLocalized(out)
+	fatalIfError(t, err)
+	if string(m["Caption"]["en-GB"]) != "A red square" || string(m["Caption"]["fr"]) != "Un carré rouge" {
+		t.Errorf("Expected both captions back, got %v\n", m)
+	}
+
+	// Negative test cases.
+	for _, byLang := range []map[string]string{
+		{"en": "a", "EN": "b"},
+		{"englishlanguage": "a"},
+		{"1en": "a"},
+		{"en-": "a"},
+		{"en": "\xff"},
+	} {
+		if _, err := EmbedITXTLocalized(bs, "Caption", byLang); err == nil {
+			t.Errorf("Expected error for %q, got nil!\n", byLang)
+		}
+	}
+	if _, err := EmbedITXTLocalized(bs, "", map[string]string{"en": "a"}); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+	for _, byLang := range []map[string]string{nil, {"en": "a"}} {
+		if out, err := EmbedITXTLocalized([]byte("garbage"), "Caption", byLang); err == nil || out != nil {
+			t.Errorf("Expected nil and an error, got %q, %v\n", out, err)
+		}
+	}
+
+	// An empty map leaves the image unchanged, and the options apply.
+	same, err := EmbedITXTLocalized(bs, "Caption", nil)
+	fatalIfError(t, err)
+	if !bytes.Equal(same, bs) {
+		t.Errorf("Expected the image back unchanged\n")
+	}
+
+	bom := append([]byte{0xef, 0xbb, 0xbf}, bs...)
+	out, err = EmbedITXTLocalized(bom, "Caption", map[string]string{"en": "a"}, WithLenientBOM(), WithSoftware("tool"))
+	fatalIfError(t, err)
+	if act := textValues(t, out, "Software"); len(act) != 1 || act[0] != "tool" {
+		t.Errorf("Expected a single `tool` Software chunk, got %v\n", act)
+	}
+}
+
+func TestLanguageTags(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	tags, err := LanguageTags(bs)
+	fatalIfError(t, err)
+	if tags == nil || len(tags) != 0 {
+		t.Errorf("Expected no tags, got %v\n", tags)
+	}
+
+	out, err := EmbedITXTLocalized(bs, "Title", map[string]string{"fr": "Rouge", "en": "Red"})
+	fatalIfError(t, err)
+	out, err = EmbedITXTFull(out, "Description", "A red square", CompressionFlagOn, CompressionZlib, "en", "")
+	fatalIfError(t, err)
+	out, err = EmbedITXT(out, "Author", "someone")
+	fatalIfError(t, err)
+
+	tags, err = LanguageTags(out)
+	fatalIfError(t, err)
+	if strings.Join(tags, ",") != "en,fr" {
+		t.Errorf("Expected [en fr], got %v\n", tags)
+	}
+
+	// Tags differing only in case are listed once, as first spelt.
+	out, err = EmbedITXTFull(bs, "Title", "Red", CompressionFlagOff, CompressionNone, "en-US", "")
+	fatalIfError(t, err)
+	out, err = EmbedITXTFull(out, "Description", "A red square", CompressionFlagOff, CompressionNone, "en-us", "")
+	fatalIfError(t, err)
+	tags, err = LanguageTags(out)
+	fatalIfError(t, err)
+	if len(tags) != 1 || tags[0] != "en-us" {
+		t.Errorf("Expected [en-us], got %v\n", tags)
+	}
+}
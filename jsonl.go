@@ -0,0 +1,65 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// jsonlRecord is the JSON shape of a single line written by
+// `WriteMetadataJSONL`.
+type jsonlRecord struct {
+	Keyword   string `json:"keyword"`
+	ChunkType string `json:"chunk_type"`
+	Value     string `json:"value"`
+	Encoding  string `json:"encoding,omitempty"`
+}
+
+// isPrintableText returns true if `v` is valid UTF-8 without control
+// characters other than tabs and line breaks.
+func isPrintableText(v []byte) bool {
+	if !utf8.Valid(v) {
+		return false
+	}
+	for _, r := range string(v) {
+		if unicode.IsControl(r) && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteMetadataJSONL writes one JSON object per text chunk in the PNG data to
+// `w`, each on its own line, in file order:
+//
+//	{"keyword":"...","chunk_type":"tEXt","value":"..."}
+//
+// Compressed text is inflated.  Values which are not printable UTF-8 text are
+// base64-encoded and flagged with `"encoding":"base64"`.  Records are written
+// as they are decoded rather than collected first.
+func WriteMetadataJSONL(w io.Writer, data []byte) error {
+	chunks, err := newOptions(nil).scanChunks(data)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	return eachTextRecord(data, chunks, func(rec textRecord) error {
+		jr := jsonlRecord{
+			Keyword:   rec.keyword,
+			ChunkType: rec.ct,
+			Value:     string(rec.value),
+		}
+		if !isPrintableText(rec.value) {
+			jr.Value = base64.StdEncoding.EncodeToString(rec.value)
+			jr.Encoding = "base64"
+		}
+		return enc.Encode(jr)
+	})
+}
@@ -0,0 +1,50 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestWriteMetadataJSONL(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXT(bs, "Text", "TextValue")
+	fatalIfError(t, err)
+	out, err = EmbedITXT(out, "Intl", "IntlValue")
+	fatalIfError(t, err)
+	out, err = EmbedTEXT(out, "Binary", string([]byte{0, 1, 2, 0xff}))
+	fatalIfError(t, err)
+
+	buf := &bytes.Buffer{}
+	fatalIfError(t, WriteMetadataJSONL(buf, out))
+
+	n := 0
+	sc := bufio.NewScanner(buf)
+	for sc.Scan() {
+		n++
+		rec := jsonlRecord{}
+		fatalIfError(t, json.Unmarshal(sc.Bytes(), &rec))
+
+		switch rec.Keyword {
+		case "Binary":
+			if rec.Encoding != "base64" || rec.Value != "AAEC/w==" {
+				t.Errorf("Expected base64 value, got %+v\n", rec)
+			}
+		case "Intl":
+			if rec.ChunkType != "iTXt" || rec.Value != "IntlValue" || rec.Encoding != "" {
+				t.Errorf("Unexpected record %+v\n", rec)
+			}
+		}
+	}
+	if n != 3 {
+		t.Errorf("Expected 3 lines, got %d\n", n)
+	}
+}
@@ -0,0 +1,145 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// maxKeywordLength is the longest keyword, in bytes, the png specification
+// permits for text chunks.
+const maxKeywordLength = 79
+
+// RegisteredKeywords lists the predefined text chunk keywords of the png
+// specification, in the order it gives them.
+var RegisteredKeywords = []string{
+	"Title",
+	"Author",
+	"Description",
+	"Copyright",
+	"Creation Time",
+	"Software",
+	"Disclaimer",
+	"Warning",
+	"Source",
+	"Comment",
+}
+
+var (
+	// ErrKeywordEmpty is wrapped by a `KeywordError` for empty keywords.
+	ErrKeywordEmpty = errors.New("keyword is empty")
+
+	// ErrKeywordTooLong is wrapped by a `KeywordError` for keywords longer
+	// than 79 bytes.
+	ErrKeywordTooLong = errors.New("keyword is too long")
+
+	// ErrKeywordNull is wrapped by a `KeywordError` for keywords containing a
+	// null byte, which would terminate them early.
+	ErrKeywordNull = errors.New("keyword contains a null byte")
+
+	// ErrKeywordBadChar is wrapped by a `KeywordError` for keywords with
+	// characters or spacing the png specification forbids.
+	ErrKeywordBadChar = errors.New("keyword contains an invalid character")
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// KeywordError reports a keyword rejected by `ValidateKeyword`.  It wraps one
+// of the `ErrKeyword*` sentinels.
+type KeywordError struct {
+	Keyword string
+	Reason  string
+
+	err error
+}
+
+func (e *KeywordError) Error() string {
+	return fmt.Sprintf("invalid keyword (%q): %s", e.Keyword, e.Reason)
+}
+
+func (e *KeywordError) Unwrap() error {
+	return e.err
+}
+
+// ValidateKeyword checks `k` against the png specification's rules for text
+// chunk keywords: 1 to 79 bytes of printable Latin-1, without leading,
+// trailing or consecutive spaces.  Violations are reported as a
+// `*KeywordError`.
+func ValidateKeyword(k string) error {
+	fail := func(err error, reason string) error {
+		return &KeywordError{Keyword: k, Reason: reason, err: err}
+	}
+
+	if len(k) == 0 {
+		return fail(ErrKeywordEmpty, ErrKeywordEmpty.Error())
+	}
+	if len(k) > maxKeywordLength {
+		return fail(ErrKeywordTooLong, fmt.Sprintf("%d bytes exceeds the %d byte limit", len(k), maxKeywordLength))
+	}
+	for i := 0; i < len(k); i++ {
+		c := k[i]
+		switch {
+		case c == 0:
+			return fail(ErrKeywordNull, fmt.Sprintf("null byte at offset %d", i))
+		case c < 32 || (c > 126 && c < 161):
+			return fail(ErrKeywordBadChar, fmt.Sprintf("byte 0x%02x at offset %d is not printable Latin-1", c, i))
+		case c == ' ' && (i == 0 || i == len(k)-1):
+			return fail(ErrKeywordBadChar, "leading or trailing space")
+		case c == ' ' && k[i-1] == ' ':
+			return fail(ErrKeywordBadChar, fmt.Sprintf("consecutive spaces at offset %d", i-1))
+		}
+	}
+	return nil
+}
+
+// keyword validates the keyword `k` for embedding.  Under
+// `WithKeywordTruncation`, an overlong keyword is first cut to 79 bytes,
+// backing off to a rune boundary when `runes` is set, and a warning is
+// reported.
+func (o *options) keyword(k string, runes bool) (string, error) {
+	if o.truncateKeywords && len(k) > maxKeywordLength {
+		n := maxKeywordLength
+		for runes && n > 0 && !utf8.RuneStart(k[n]) {
+			n--
+		}
+		t := strings.TrimRight(k[:n], " ")
+		o.warnf("truncated keyword (%s) to %d bytes: %s", k, len(t), t)
+		k = t
+	}
+	return k, ValidateKeyword(k)
+}
+
+// PresentRegisteredKeywords returns which of the `RegisteredKeywords` have a
+// text record in the PNG data, in the order of `RegisteredKeywords`.  This
+// lets tools check that required attribution fields exist.
+func PresentRegisteredKeywords(data []byte) ([]string, error) {
+	kvs, err := ExtractAll(data)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []string{}
+	for _, k := range RegisteredKeywords {
+		if _, ok := kvs[k]; ok {
+			ret = append(ret, k)
+		}
+	}
+	return ret, nil
+}
+
+// ValidateValueNoNull checks that the tEXt or zTXt text `value` holds no null
+// byte, as the png specification requires.  Readers find the value after the
+// keyword's null separator, so a null inside the value survives this library,
+// but strict readers may truncate or reject it.
+func ValidateValueNoNull(value []byte) error {
+	if i := bytes.IndexByte(value, NULL_SEPERATOR); i >= 0 {
+		return fmt.Errorf("value contains a null byte at offset %d", i)
+	}
+	return nil
+}
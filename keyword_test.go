@@ -0,0 +1,157 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestValidateKeyword(t *testing.T) {
+	for _, tc := range []struct {
+		k   string
+		err error
+	}{
+		// Negative test cases.
+		{k: "", err: ErrKeywordEmpty},
+		{k: strings.Repeat("k", 80), err: ErrKeywordTooLong},
+		{k: "Ke\x00y", err: ErrKeywordNull},
+		{k: "Ke\ny", err: ErrKeywordBadChar},
+		{k: " Key", err: ErrKeywordBadChar},
+		{k: "Key ", err: ErrKeywordBadChar},
+		{k: "Creation  Time", err: ErrKeywordBadChar},
+
+		// Positive test cases.
+		{k: "Creation Time", err: nil},
+		{k: strings.Repeat("k", 79), err: nil},
+		{k: "pngembed:attachment:sig", err: nil},
+		{k: "caf\xe9", err: nil},
+	} {
+		err := ValidateKeyword(tc.k)
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("Expected %v for %q, got %v\n", tc.err, tc.k, err)
+		}
+	}
+}
+
+func TestEmbedKeywordError(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	long := strings.Repeat("k", 80)
+	for _, embed := range []func([]byte, string, interface{}, ...Option) ([]byte, error){
+		EmbedTEXT, EmbedITXT, EmbedZTXT, EmbedITXTCompressed,
+	} {
+		_, err := embed(bs, long, "Value")
+
+		var ke *KeywordError
+		if !errors.As(err, &ke) {
+			t.Fatalf("Expected a KeywordError, got %v\n", err)
+		}
+		if ke.Keyword != long || !strings.Contains(ke.Reason, "79") {
+			t.Errorf("Unexpected KeywordError %+v\n", ke)
+		}
+		if !errors.Is(err, ErrKeywordTooLong) {
+			t.Errorf("Expected ErrKeywordTooLong, got %v\n", err)
+		}
+	}
+}
+
+func TestWithKeywordTruncation(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	long := strings.Repeat("k", 78) + "éé"
+	if _, err := EmbedTEXT(bs, long, "v"); !errors.Is(err, ErrKeywordTooLong) {
+		t.Errorf("Expected ErrKeywordTooLong by default, got %v\n", err)
+	}
+
+	warnings := []string{}
+	opts := []Option{
+		WithKeywordTruncation(),
+		WithWarningHandler(func(msg string) { warnings = append(warnings, msg) }),
+	}
+
+	// tEXt keywords are cut at byte 79, even mid-sequence.
+	out, err := EmbedTEXT(bs, long, "v", opts...)
+	fatalIfError(t, err)
+	if _, found, _ := GetValue(out, long[:79]); !found {
+		t.Errorf("Expected the keyword cut to 79 bytes\n")
+	}
+
+	// iTXt keywords back off to the start of the split rune.
+	out, err = EmbedITXT(bs, long, "v", opts...)
+	fatalIfError(t, err)
+	if _, found, _ := GetValue(out, long[:78]); !found {
+		t.Errorf("Expected the keyword cut to 78 bytes\n")
+	}
+
+	// Pairs are truncated too.
+	out, err = EmbedPairs(bs, []KV{{Key: strings.Repeat("p", 100), Value: 1}}, opts...)
+	fatalIfError(t, err)
+	if _, found, _ := GetValue(out, strings.Repeat("p", 79)); !found {
+		t.Errorf("Expected the pair keyword cut to 79 bytes\n")
+	}
+
+	if len(warnings) != 3 {
+		t.Errorf("Expected 3 warnings, got %v\n", warnings)
+	}
+}
+
+func TestPresentRegisteredKeywords(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedPairs(bs, []KV{
+		{Key: "Copyright", Value: "2024 Someone"},
+		{Key: "Author", Value: "Someone"},
+		{Key: "author", Value: "not registered"},
+	})
+	fatalIfError(t, err)
+
+	got, err := PresentRegisteredKeywords(out)
+	fatalIfError(t, err)
+	if strings.Join(got, ",") != "Author,Copyright" {
+		t.Errorf("Expected [Author Copyright], got %v\n", got)
+	}
+
+	got, err = PresentRegisteredKeywords(bs)
+	fatalIfError(t, err)
+	if len(got) != 0 {
+		t.Errorf("Expected no keywords, got %v\n", got)
+	}
+}
+
+func TestValidateValueNoNull(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	fatalIfError(t, ValidateValueNoNull([]byte("plain text")))
+	fatalIfError(t, ValidateValueNoNull(nil))
+	if err := ValidateValueNoNull([]byte("a\x00b")); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+
+	// Without the option, the null survives a round trip.
+	out, err := EmbedTEXT(bs, "Key", "a\x00b")
+	fatalIfError(t, err)
+	if v, _, _ := GetValue(out, "Key"); string(v) != "a\x00b" {
+		t.Errorf("Expected the value intact, got %q\n", v)
+	}
+
+	// Positive test cases.
+	_, err = EmbedTEXT(bs, "Key", "ab", WithNoNullValues())
+	fatalIfError(t, err)
+
+	// Negative test cases.
+	if _, err := EmbedTEXT(bs, "Key", "a\x00b", WithNoNullValues()); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+	if _, err := EmbedPairs(bs, []KV{{Key: "Key", Value: "a\x00b"}}, WithNoNullValues()); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
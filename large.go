@@ -0,0 +1,90 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// PartsSuffix is appended to a keyword to form the keyword of the chunk which
+// records how many parts `EmbedLargeJSON` split its value into.
+const PartsSuffix = ":__parts"
+
+////////////////////////////////////////////////////////////////////////////////
+
+// EmbedLargeJSON serializes `v` like `EmbedTEXT` and splits the result across
+// as many tEXt chunks of at most `chunkSize` bytes as needed, keyed `k:0`,
+// `k:1`, ... in order.  The number of parts is stored under `k:__parts`.  Use
+// this when the decoders reading the file limit the size of a single chunk.
+func EmbedLargeJSON(data []byte, k string, v interface{}, chunkSize int, opts ...Option) ([]byte, error) {
+	if err := ValidateKeyword(k + PartsSuffix); err != nil {
+		return nil, err
+	}
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("invalid chunk size (%d)", chunkSize)
+	}
+
+	o := newOptions(opts)
+	data = o.stripBOM(data)
+
+	val, err := o.serializeTEXT(v)
+	if err != nil {
+		return nil, err
+	}
+
+	block := []byte{}
+	n := 0
+	for off := 0; off < len(val) || n == 0; off += chunkSize {
+		end := off + chunkSize
+		if end > len(val) {
+			end = len(val)
+		}
+		pk := fmt.Sprintf("%s:%d", k, n)
+		if err := ValidateKeyword(pk); err != nil {
+			return nil, err
+		}
+		pngChunk, _ := buildChunk(`tEXt`, formatTEXTChunk(val[off:end], pk))
+		block = append(block, pngChunk...)
+		n++
+	}
+	pngChunk, _ := buildChunk(`tEXt`, formatTEXTChunk([]byte(strconv.Itoa(n)), k+PartsSuffix))
+	block = append(block, pngChunk...)
+
+	out, err := o.embed(data, block)
+	if err != nil {
+		return nil, err
+	}
+	return o.postEmbed(out, nil)
+}
+
+// ExtractLargeJSON reassembles the serialized value split by `EmbedLargeJSON`
+// under `k`.
+func ExtractLargeJSON(data []byte, k string) ([]byte, error) {
+	m, err := ExtractAll(data)
+	if err != nil {
+		return nil, err
+	}
+
+	pv, ok := m[k+PartsSuffix]
+	if !ok {
+		return nil, fmt.Errorf("keyword (%s) not found", k+PartsSuffix)
+	}
+	n, err := strconv.Atoi(string(pv))
+	if err != nil || n < 1 {
+		return nil, errors.New("invalid part count for " + k)
+	}
+
+	ret := []byte{}
+	for i := 0; i < n; i++ {
+		part, ok := m[fmt.Sprintf("%s:%d", k, i)]
+		if !ok {
+			return nil, fmt.Errorf("part %d of %d missing for %s", i, n, k)
+		}
+		ret = append(ret, part...)
+	}
+	return ret, nil
+}
@@ -0,0 +1,59 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestLargeJSON(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	val := bytes.Repeat([]byte("0123456789abcdef"), 1<<16)
+	out, err := EmbedLargeJSON(bs, "big", string(val), 256<<10)
+	fatalIfError(t, err)
+
+	cis, err := ListChunks(out, "tEXt")
+	fatalIfError(t, err)
+	if len(cis) != 5 {
+		t.Errorf("Expected 4 parts and a count, got %d chunks\n", len(cis))
+	}
+	for _, ci := range cis {
+		if ci.Length > 256<<10+len("big:0")+1 {
+			t.Errorf("Chunk of %d bytes exceeds the part size\n", ci.Length)
+		}
+	}
+
+	act, err := ExtractLargeJSON(out, "big")
+	fatalIfError(t, err)
+	if !bytes.Equal(act, val) {
+		t.Errorf("Reassembled value does not match\n")
+	}
+
+	// Small structured values still round-trip through a single part.
+	out, err = EmbedLargeJSON(bs, "small", struct{ A int }{1}, 256<<10)
+	fatalIfError(t, err)
+	act, err = ExtractLargeJSON(out, "small")
+	fatalIfError(t, err)
+	if string(act) != `{"A":1}` {
+		t.Errorf("Expected {\"A\":1}, got %s\n", act)
+	}
+
+	// Transcoding happens once, before splitting.
+	out, err = EmbedLargeJSON(bs, "latin", "ééé", 2, WithLatin1Transcode())
+	fatalIfError(t, err)
+	act, err = ExtractLargeJSON(out, "latin")
+	fatalIfError(t, err)
+	if string(act) != "\xe9\xe9\xe9" {
+		t.Errorf("Expected Latin-1 bytes, got %q\n", act)
+	}
+
+	if _, err := ExtractLargeJSON(bs, "big"); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
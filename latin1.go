@@ -0,0 +1,69 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// toLatin1 transcodes the UTF-8 text `val` to ISO 8859-1, as required for the
+// text of tEXt chunks.  Invalid UTF-8, or characters outside Latin-1, are
+// rejected.
+func toLatin1(val []byte) ([]byte, error) {
+	out := make([]byte, 0, len(val))
+	for i := 0; i < len(val); {
+		r, sz := utf8.DecodeRune(val[i:])
+		if r == utf8.RuneError && sz <= 1 {
+			return nil, fmt.Errorf("invalid UTF-8 at byte %d", i)
+		}
+		if r > 0xff {
+			return nil, fmt.Errorf("character %q at byte %d is not representable in Latin-1", r, i)
+		}
+		out = append(out, byte(r))
+		i += sz
+	}
+	return out, nil
+}
+
+// fromLatin1 decodes ISO 8859-1 text into a UTF-8 Go string.
+func fromLatin1(val []byte) string {
+	rs := make([]rune, len(val))
+	for i, b := range val {
+		rs[i] = rune(b)
+	}
+	return string(rs)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ExtractTEXTLatin1 is like `ExtractTEXT` but decodes the Latin-1 text of each
+// record into a UTF-8 string.  Use it to read values written with
+// `WithLatin1Transcode`.
+func ExtractTEXTLatin1(data []byte, opts ...Option) (map[string]string, error) {
+	m, err := ExtractTEXT(data, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]string, len(m))
+	for k, v := range m {
+		ret[fromLatin1([]byte(k))] = fromLatin1(v)
+	}
+	return ret, nil
+}
+
+// foldLatin1 lower-cases the Latin-1 keyword `k` byte by byte: A-Z and the
+// accented capitals 0xC0-0xDE, except the multiplication sign 0xD7, map to
+// their small letters.  All other bytes are kept as they are.
+func foldLatin1(k string) string {
+	b := []byte(k)
+	for i, c := range b {
+		if (c >= 'A' && c <= 'Z') || (c >= 0xc0 && c <= 0xde && c != 0xd7) {
+			b[i] = c + 0x20
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,85 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestLatin1Transcode(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXT(bs, "Drink", "café", WithLatin1Transcode())
+	fatalIfError(t, err)
+
+	raw, err := ExtractTEXT(out)
+	fatalIfError(t, err)
+	if string(raw["Drink"]) != "caf\xe9" {
+		t.Errorf("Expected Latin-1 bytes, got %q\n", raw["Drink"])
+	}
+
+	m, err := ExtractTEXTLatin1(out)
+	fatalIfError(t, err)
+	if m["Drink"] != "café" {
+		t.Errorf("Expected café, got %q\n", m["Drink"])
+	}
+
+	if _, err := EmbedTEXT(bs, "Drink", "日本茶", WithLatin1Transcode()); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+	if _, err := EmbedTEXT(bs, "Drink", "\xff", WithLatin1Transcode()); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
+
+func TestAutoPromoteUTF8(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXT(bs, "Language", "日本語", WithAutoPromoteUTF8())
+	fatalIfError(t, err)
+	out, err = EmbedTEXT(out, "Drink", "café", WithAutoPromoteUTF8())
+	fatalIfError(t, err)
+
+	text, itxt, _, err := ExtractByType(out)
+	fatalIfError(t, err)
+	if string(itxt["Language"]) != "日本語" || len(text) != 1 {
+		t.Errorf("Expected Language promoted to iTXt, got tEXt %q iTXt %q\n", text, itxt)
+	}
+	if string(text["Drink"]) != "caf\xe9" {
+		t.Errorf("Expected Drink transcoded to Latin-1 tEXt, got %q\n", text["Drink"])
+	}
+
+	// The multi-key embedders promote too.
+	out, err = EmbedPairs(bs, []KV{{Key: "Language", Value: "日本語"}, {Key: "Drink", Value: "café"}}, WithAutoPromoteUTF8())
+	fatalIfError(t, err)
+	text, itxt, _, err = ExtractByType(out)
+	fatalIfError(t, err)
+	if string(itxt["Language"]) != "日本語" || string(text["Drink"]) != "caf\xe9" {
+		t.Errorf("Expected EmbedPairs to promote, got tEXt %q iTXt %q\n", text, itxt)
+	}
+	out, err = EmbedMulti(bs, map[string]interface{}{"Language": "日本語"}, WithAutoPromoteUTF8())
+	fatalIfError(t, err)
+	if _, itxt, _, _ := ExtractByType(out); string(itxt["Language"]) != "日本語" {
+		t.Errorf("Expected EmbedMulti to promote, got %q\n", itxt)
+	}
+
+	// Embedders which do not promote store the value as without the option.
+	out, err = EmbedZTXT(bs, "Language", "日本語", WithAutoPromoteUTF8())
+	fatalIfError(t, err)
+	if v, _, _ := GetValue(out, "Language"); string(v) != "日本語" {
+		t.Errorf("Expected the value stored as is, got %q\n", v)
+	}
+
+	// Negative test cases.
+	if _, err := EmbedTEXT(bs, "Language", "日本語", WithLatin1Transcode()); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+	if _, err := EmbedTEXT(bs, "Binary", "\xff", WithAutoPromoteUTF8()); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
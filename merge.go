@@ -0,0 +1,161 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/sabhiram/pngr"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// mergeRecord is the record of the last text chunk carrying a keyword, and the
+// chunk it was read from.
+type mergeRecord struct {
+	c     chunk
+	value []byte
+}
+
+// lastTextRecords returns the record of the last `tEXt`, `iTXt` or `zTXt`
+// chunk carrying each keyword of the png data, with compressed text inflated.
+func lastTextRecords(data []byte) (map[string]mergeRecord, error) {
+	chunks, err := scanChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[string]mergeRecord{}
+	for _, c := range chunks {
+		err := eachTextRecord(data, []chunk{c}, func(rec textRecord) error {
+			if rec.keyword != "" {
+				ret[rec.keyword] = mergeRecord{c: c, value: rec.value}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// MergeMetadata copies the text metadata of the `overlay` PNG into the `base`
+// PNG and returns the result.  Keys only present in `overlay` are added.  For
+// keys present in both with differing values, `resolve` picks the value to
+// keep; a nil `resolve` lets the overlay win.  Overlay chunks are copied
+// verbatim, keeping their chunk type and compression, and replace every chunk
+// carrying the same key in `base`.  A resolved value matching neither side is
+// written to a new chunk of the overlay chunk's type.  The image is copied
+// once, however many keys are merged.
+func MergeMetadata(base, overlay []byte, resolve func(key string, baseVal, overlayVal []byte) []byte) ([]byte, error) {
+	bm, err := lastTextRecords(base)
+	if err != nil {
+		return nil, err
+	}
+	om, err := lastTextRecords(overlay)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(om))
+	for k := range om {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	block, replaced := []byte{}, map[string]bool{}
+	for _, k := range keys {
+		orec := om[k]
+		pngChunk := orec.c.raw(overlay)
+		if brec, ok := bm[k]; ok {
+			if bytes.Equal(brec.value, orec.value) {
+				continue
+			}
+			if resolve != nil {
+				v := resolve(k, brec.value, orec.value)
+				if bytes.Equal(v, brec.value) {
+					continue
+				}
+				if !bytes.Equal(v, orec.value) {
+					if pngChunk, err = rebuildTextChunk(overlay, orec.c, k, v); err != nil {
+						return nil, err
+					}
+				}
+			}
+			replaced[k] = true
+		}
+		block = append(block, pngChunk...)
+	}
+	if len(block) == 0 {
+		return base, nil
+	}
+
+	out, err := stripText(base, func(ct, ck string) bool { return replaced[ck] })
+	if err != nil {
+		return nil, err
+	}
+	return embed(out, block)
+}
+
+// rebuildTextChunk builds a chunk of the same type as the text chunk `c` of
+// the png data, holding `v` under keyword `k`.  Compression, and the language
+// tag and translated keyword of an iTXt chunk, are kept.
+func rebuildTextChunk(data []byte, c chunk, k string, v []byte) ([]byte, error) {
+	switch c.ct {
+	case "zTXt":
+		return buildChunk(`zTXt`, formatZTXTChunk(deflate(v), k))
+	case "iTXt":
+		rec, err := parseITXTRecord(c.data(data))
+		if err != nil {
+			return nil, err
+		}
+		if rec.compressionFlag != 0 {
+			v = deflate(v)
+		}
+		return buildChunk(`iTXt`, formatITXTChunk(v, k, int(rec.compressionFlag), int(rec.compressionMethod), rec.languageTag, rec.translatedKeyword))
+	default:
+		return buildChunk(`tEXt`, formatTEXTChunk(v, k))
+	}
+}
+
+// CopyKeys copies the text chunks carrying any of `keys` from the `src` PNG
+// into the `dst` PNG and returns the result.  The chunks are copied verbatim,
+// keeping their chunk type and compression.  Text chunks in `dst` carrying
+// one of the copied keys are dropped first.  Keys missing from `src` are
+// skipped, but `dst` is validated even if none are found.  A chunk to copy
+// whose CRC does not match is an error, so corruption is not spread.
+func CopyKeys(src, dst []byte, keys ...string) ([]byte, error) {
+	want := map[string]bool{}
+	for _, k := range keys {
+		want[k] = true
+	}
+
+	chunks, err := scanChunks(src)
+	if err != nil {
+		return nil, err
+	}
+	block, found := []byte{}, map[string]bool{}
+	for _, c := range chunks {
+		if k, ok := c.keyword(src); ok && want[k] {
+			if !c.crcValid(src) {
+				return nil, pngr.ErrBadCRC
+			}
+			block = append(block, c.raw(src)...)
+			found[k] = true
+		}
+	}
+	if len(block) == 0 {
+		if _, err := InjectionOffset(dst); err != nil {
+			return nil, err
+		}
+		return dst, nil
+	}
+
+	dst, err = stripText(dst, func(ct, ck string) bool { return found[ck] })
+	if err != nil {
+		return nil, err
+	}
+	return embed(dst, block)
+}
@@ -0,0 +1,130 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/sabhiram/pngr"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestMergeMetadata(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	base, err := EmbedTEXT(bs, "Author", "alice")
+	fatalIfError(t, err)
+	base, err = EmbedTEXT(base, "Shared", "same")
+	fatalIfError(t, err)
+
+	overlay, err := EmbedITXT(bs, "Author", "bob")
+	fatalIfError(t, err)
+	overlay, err = EmbedTEXT(overlay, "Shared", "same")
+	fatalIfError(t, err)
+	overlay, err = EmbedTEXT(overlay, "Title", "Red")
+	fatalIfError(t, err)
+	overlay, err = EmbedZTXT(overlay, "Notes", strings.Repeat("note ", 100))
+	fatalIfError(t, err)
+
+	concat := func(k string, bv, ov []byte) []byte {
+		return append(append(append([]byte{}, bv...), '+'), ov...)
+	}
+	for _, tc := range []struct {
+		resolve func(string, []byte, []byte) []byte
+		author  string
+	}{
+		{resolve: nil, author: "bob"},
+		{resolve: concat, author: "alice+bob"},
+	} {
+		out, err := MergeMetadata(base, overlay, tc.resolve)
+		fatalIfError(t, err)
+
+		m, err := ExtractAll(out)
+		fatalIfError(t, err)
+		if len(m) != 3 || string(m["Title"]) != "Red" || string(m["Shared"]) != "same" {
+			t.Errorf("Unexpected merged metadata %v\n", m)
+		}
+		if string(m["Author"]) != tc.author {
+			t.Errorf("Expected Author %s, got %s\n", tc.author, m["Author"])
+		}
+		if act := textValues(t, out, "Shared"); len(act) != 1 {
+			t.Errorf("Expected 1 Shared chunk, got %d\n", len(act))
+		}
+
+		// Merged chunks keep their type and compression.
+		text, itxt, ztxt, err := ExtractByType(out)
+		fatalIfError(t, err)
+		if _, ok := text["Author"]; ok || string(itxt["Author"]) != tc.author {
+			t.Errorf("Expected Author as iTXt, got %v %v\n", text, itxt)
+		}
+		if len(ztxt["Notes"]) != 500 {
+			t.Errorf("Expected Notes as zTXt, got %v\n", ztxt)
+		}
+	}
+
+	// A resolved base value leaves the base alone.
+	keep := func(k string, bv, ov []byte) []byte { return bv }
+	out, err := MergeMetadata(base, overlay, keep)
+	fatalIfError(t, err)
+	if act := textValues(t, out, "Author"); len(act) != 1 || act[0] != "alice" {
+		t.Errorf("Expected Author alice, got %v\n", act)
+	}
+}
+
+func TestCopyKeys(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	src, err := EmbedTEXT(bs, "Author", "alice")
+	fatalIfError(t, err)
+	src, err = EmbedZTXT(src, "Notes", strings.Repeat("note ", 100))
+	fatalIfError(t, err)
+	src, err = EmbedITXT(src, "Secret", "do not copy")
+	fatalIfError(t, err)
+	dst, err := EmbedTEXT(bs, "Author", "bob")
+	fatalIfError(t, err)
+
+	out, err := CopyKeys(src, dst, "Author", "Notes", "Missing")
+	fatalIfError(t, err)
+
+	text, _, ztxt, err := ExtractByType(out)
+	fatalIfError(t, err)
+	if len(text) != 1 || string(text["Author"]) != "alice" {
+		t.Errorf("Expected Author replaced by alice, got %v\n", text)
+	}
+	if len(ztxt) != 1 || len(ztxt["Notes"]) != 500 {
+		t.Errorf("Expected Notes copied as zTXt, got %v\n", ztxt)
+	}
+	if found, _ := HasKey(out, "Secret"); found {
+		t.Errorf("Expected Secret not to be copied\n")
+	}
+
+	// Copying no present keys leaves the destination alone.
+	out, err = CopyKeys(src, dst, "Missing")
+	fatalIfError(t, err)
+	if !bytes.Equal(out, dst) {
+		t.Errorf("Expected the destination unchanged\n")
+	}
+
+	// Negative test cases.
+	if out, err := CopyKeys(src, []byte("garbage"), "Missing"); err == nil || out != nil {
+		t.Errorf("Expected nil and an error, got %q, %v\n", out, err)
+	}
+	bad := append([]byte{}, src...)
+	chunks, err := scanChunks(bad)
+	fatalIfError(t, err)
+	for _, c := range chunks {
+		if c.ct == "tEXt" {
+			bad[c.end()-1] ^= 0xff
+		}
+	}
+	if _, err := CopyKeys(bad, dst, "Author"); !errors.Is(err, pngr.ErrBadCRC) {
+		t.Errorf("Expected pngr.ErrBadCRC, got %v\n", err)
+	}
+}
@@ -0,0 +1,117 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"sort"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// KV is a single key-value pair to embed.
+type KV struct {
+	Key   string
+	Value interface{}
+}
+
+// EmbedMulti embeds every key-value pair of `kv` into its own `tEXt` chunk.
+// Keys are sorted before embedding, so the same map always yields the same
+// bytes regardless of Go's map iteration order.  The chunks follow IHDR in
+// sorted key order.
+func EmbedMulti(data []byte, kv map[string]interface{}, opts ...Option) ([]byte, error) {
+	return EmbedPairs(data, sortedPairs(kv), opts...)
+}
+
+// sortedPairs returns the pairs of `kv` sorted by key.
+func sortedPairs(kv map[string]interface{}) []KV {
+	keys := make([]string, 0, len(kv))
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]KV, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, KV{Key: k, Value: kv[k]})
+	}
+	return kvs
+}
+
+// EmbedPairs is like `EmbedMulti` but takes the pairs as a slice, and embeds
+// them in the order given.  Under `WithAutoCompress`, large compressible
+// values are written as `zTXt` chunks instead.
+func EmbedPairs(data []byte, kvs []KV, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	data = o.stripBOM(data)
+
+	if len(kvs) == 0 {
+		if err := checkSignature(data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+
+	// Build all the chunks up front, so the image is only copied once.
+	block, kvs, err := o.pairsBlock(kvs)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := o.embed(data, block)
+	if err != nil {
+		return nil, err
+	}
+	return o.postEmbed(out, kvs)
+}
+
+// pairsBlock builds the text chunks holding `kvs`, back to back.  Keywords may
+// be truncated, so the pairs are returned as embedded, in a copy.
+func (o *options) pairsBlock(kvs []KV) ([]byte, []KV, error) {
+	block := []byte{}
+	kvs = append([]KV{}, kvs...)
+	for i := range kvs {
+		kv := &kvs[i]
+		k, err := o.keyword(kv.Key, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		kv.Key = k
+
+		val, promote, err := o.serializePromoting(kv.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ct, cdata := `tEXt`, formatTEXTChunk(val, kv.Key)
+		if promote {
+			ct, cdata = `iTXt`, formatITXTChunk(val, kv.Key, CompressionFlagOff, CompressionNone, "", "")
+		}
+		if o.autoCompress {
+			if z, ok := compressLarge(val); ok && !promote {
+				ct, cdata = `zTXt`, formatZTXTChunk(z, kv.Key)
+			}
+			if o.chunkTypes != nil {
+				o.chunkTypes[kv.Key] = ct
+			}
+		}
+		pngChunk, err := buildChunk(ct, cdata)
+		if err != nil {
+			return nil, nil, err
+		}
+		block = append(block, pngChunk...)
+	}
+	return block, kvs, nil
+}
+
+// SetMetadata replaces the whole text metadata of the PNG data with `kv`: all
+// existing `tEXt`, `iTXt` and `zTXt` chunks are dropped, and the pairs of `kv`
+// are embedded as by `EmbedMulti`.  Afterwards the keys of `kv` are the only
+// ones present.  On error the input is left untouched.
+func SetMetadata(data []byte, kv map[string]interface{}, opts ...Option) ([]byte, error) {
+	data = newOptions(opts).stripBOM(data)
+	stripped, err := StripAllText(data)
+	if err != nil {
+		return nil, err
+	}
+	return EmbedMulti(stripped, kv, opts...)
+}
@@ -0,0 +1,175 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestEmbedMulti(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	kv := map[string]interface{}{
+		"delta": "d", "alpha": 1, "charlie": 3.0, "bravo": struct{ B bool }{true},
+		"echo": "e", "foxtrot": "f", "golf": "g", "hotel": "h",
+	}
+
+	out0, err := EmbedMulti(bs, kv)
+	fatalIfError(t, err)
+	for i := 0; i < 10; i++ {
+		out1, err := EmbedMulti(bs, kv)
+		fatalIfError(t, err)
+		if !bytes.Equal(out0, out1) {
+			t.Fatalf("Expected identical output from identical maps\n")
+		}
+	}
+
+	m, err := ExtractAll(out0)
+	fatalIfError(t, err)
+	if len(m) != len(kv) || string(m["bravo"]) != `{"B":true}` || string(m["alpha"]) != "1" {
+		t.Errorf("Unexpected extracted metadata %v\n", m)
+	}
+
+	cis, err := ListChunks(out0, "tEXt")
+	fatalIfError(t, err)
+	keys := []string{}
+	for _, ci := range cis {
+		k, _, err := parseTEXT(out0[ci.Offset+8 : ci.Offset+8+ci.Length])
+		fatalIfError(t, err)
+		keys = append(keys, k)
+	}
+	if keys[0] != "alpha" || keys[len(keys)-1] != "hotel" {
+		t.Errorf("Expected chunks in sorted key order, got %v\n", keys)
+	}
+
+	pairs, err := EmbedPairs(bs, []KV{{"b", "1"}, {"a", "2"}})
+	fatalIfError(t, err)
+	if act := chunkTypes(t, pairs); len(act) != 5 {
+		t.Errorf("Expected 5 chunks, got %v\n", act)
+	}
+
+	// No pairs leaves the image as is.
+	same, err := EmbedPairs(bs, nil)
+	fatalIfError(t, err)
+	if !bytes.Equal(same, bs) {
+		t.Errorf("Expected the image back unchanged\n")
+	}
+
+	// Negative test cases.
+	if out, err := EmbedPairs([]byte("not a png"), nil); err == nil || out != nil {
+		t.Errorf("Expected nil and an error, got %v, %v\n", out, err)
+	}
+}
+
+func BenchmarkSequentialVsMulti(b *testing.B) {
+	bs, err := ioutil.ReadFile(redPng)
+	if err != nil {
+		b.Fatal(err)
+	}
+	// Pad the image out to 5 MB with a large ancillary chunk.
+	bs, err = EmbedChunk(bs, "iCCP", make([]byte, 5<<20))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	kv := map[string]interface{}{}
+	for i := 0; i < 10; i++ {
+		kv[fmt.Sprintf("Key%d", i)] = fmt.Sprintf("Value%d", i)
+	}
+
+	b.Run("Sequential", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			out := bs
+			for k, v := range kv {
+				if out, err = EmbedTEXT(out, k, v); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("Multi", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := EmbedMulti(bs, kv); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestEmbedMultiAutoCompress(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	large := strings.Repeat("compressible ", 200)
+	kv := map[string]interface{}{"Small": "s", "Large": large, "Number": 42}
+
+	report := map[string]string{}
+	out, err := EmbedMulti(bs, kv, WithAutoCompress(report))
+	fatalIfError(t, err)
+
+	exp := map[string]string{"Small": "tEXt", "Number": "tEXt", "Large": "zTXt"}
+	for k, ct := range exp {
+		if report[k] != ct {
+			t.Errorf("Expected %s for %s, got %s\n", ct, k, report[k])
+		}
+		_, got, found, err := GetValueWithType(out, k)
+		fatalIfError(t, err)
+		if !found || got != ct {
+			t.Errorf("Expected %s chunk for %s, got %s\n", ct, k, got)
+		}
+	}
+	if v, _, _ := GetValue(out, "Large"); string(v) != large {
+		t.Errorf("Expected large value to round trip\n")
+	}
+
+	// Without the option, no report is written and everything is tEXt.
+	report = map[string]string{}
+	out, err = EmbedMulti(bs, kv)
+	fatalIfError(t, err)
+	if _, ct, _, _ := GetValueWithType(out, "Large"); ct != "tEXt" || len(report) != 0 {
+		t.Errorf("Expected plain tEXt without auto-selection, got %s\n", ct)
+	}
+}
+
+func TestSetMetadata(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedMulti(bs, map[string]interface{}{"Old": 1, "Kept": "old"})
+	fatalIfError(t, err)
+	out, err = EmbedITXT(out, "OldITXT", "gone")
+	fatalIfError(t, err)
+
+	out, err = SetMetadata(out, map[string]interface{}{"Kept": "new", "New": 2})
+	fatalIfError(t, err)
+
+	m, err := ExtractAll(out)
+	fatalIfError(t, err)
+	if len(m) != 2 || string(m["Kept"]) != "new" || string(m["New"]) != "2" {
+		t.Errorf("Expected exactly Kept and New, got %v\n", m)
+	}
+	if n := len(textKeywords(t, out)); n != 2 {
+		t.Errorf("Expected 2 text chunks, got %d\n", n)
+	}
+
+	// An empty set removes all metadata.
+	out, err = SetMetadata(out, nil)
+	fatalIfError(t, err)
+	if !bytes.Equal(out, bs) {
+		t.Errorf("Expected the bare image back\n")
+	}
+
+	// Negative test cases.
+	if _, err := SetMetadata(bs, map[string]interface{}{"": 1}); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
@@ -0,0 +1,477 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+var (
+	utf8BOM = []byte{0xef, 0xbb, 0xbf}
+)
+
+// DefaultMaxChunks is the number of chunks the extractors parse before giving
+// up, unless overridden with `WithMaxChunks`.
+const DefaultMaxChunks = 10000
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Option configures the behavior of the embed and extract functions.
+type Option func(*options)
+
+type options struct {
+	lenientBOM bool
+	warn       func(msg string)
+	duplicates DuplicateStrategy
+	software   string
+	typeHints  bool
+	newlines   NewlineMode
+	maxChunks  int
+	latin1     bool
+	noJSON     bool
+	utf8       UTF8Mode
+
+	autoCompress bool
+	chunkTypes   map[string]string
+	placement    Placement
+
+	preserveMtime    bool
+	truncateKeywords bool
+	repairIHDR       bool
+
+	marshal   func(v interface{}) ([]byte, error)
+	unmarshal func(data []byte, v interface{}) error
+
+	compactSlices   bool
+	budgetRatio     float64
+	autoPromoteUTF8 bool
+	noNullValues    bool
+	foldKeys        bool
+}
+
+// newOptions applies `opts` over the library defaults.
+func newOptions(opts []Option) *options {
+	o := &options{
+		maxChunks:   DefaultMaxChunks,
+		budgetRatio: DefaultMetadataBudgetRatio,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// warnf reports a non-fatal problem to the warning handler, if any.
+func (o *options) warnf(format string, args ...interface{}) {
+	if o.warn != nil {
+		o.warn(fmt.Sprintf(format, args...))
+	}
+}
+
+// stripBOM removes a leading UTF-8 byte order mark from `data` when running in
+// lenient mode.  In strict mode `data` is returned untouched, and the magic
+// number check will reject it.
+func (o *options) stripBOM(data []byte) []byte {
+	if o.lenientBOM && bytes.HasPrefix(data, utf8BOM) {
+		o.warnf("skipped leading UTF-8 byte order mark")
+		return data[len(utf8BOM):]
+	}
+	return data
+}
+
+// serialize converts `v` to the bytes stored in a text chunk, applying the
+// value transforms selected by the options.
+func (o *options) serialize(v interface{}) ([]byte, error) {
+	if o.compactSlices {
+		if val, ok := compactSlice(v); ok {
+			return o.newlines.normalize(val), nil
+		}
+	}
+	if o.marshal != nil && !isScalar(v) {
+		val, err := o.marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return o.newlines.normalize(val), nil
+	}
+	if o.noJSON {
+		switch v.(type) {
+		case int, uint, float32, float64, string, bool, []byte:
+		default:
+			return nil, fmt.Errorf("unsupported value type %T without JSON fallback", v)
+		}
+	}
+
+	val, err := to_bytes(v)
+	if err != nil {
+		return nil, err
+	}
+	return o.newlines.normalize(val), nil
+}
+
+// isScalar returns true for the value types `to_bytes` formats directly rather
+// than encoding them as JSON.
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case int, uint, float32, float64, string:
+		return true
+	}
+	return false
+}
+
+// serializePromoting is like `serializeTEXT` but honors `WithAutoPromoteUTF8`:
+// UTF-8 text which Latin-1 cannot represent is returned as is, along with
+// true, to be written to an iTXt chunk instead; all other values are
+// transcoded to Latin-1.
+func (o *options) serializePromoting(v interface{}) ([]byte, bool, error) {
+	if !o.autoPromoteUTF8 {
+		val, err := o.serializeTEXT(v)
+		return val, false, err
+	}
+
+	val, err := o.serialize(v)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := toLatin1(val); err != nil && utf8.Valid(val) {
+		return val, true, nil
+	}
+	lo := *o
+	lo.latin1 = true
+	val, err = lo.serializeTEXT(v)
+	return val, false, err
+}
+
+// decode unmarshals the stored value `val` into `target`, with the function
+// set by `WithUnmarshal` or else as JSON.
+func (o *options) decode(val []byte, target interface{}) error {
+	if o.unmarshal != nil {
+		return o.unmarshal(val, target)
+	}
+	return json.Unmarshal(val, target)
+}
+
+// serializeTEXT is like `serialize` but for values bound for tEXt chunks, which
+// are additionally checked for null bytes and transcoded to Latin-1 when
+// requested.
+func (o *options) serializeTEXT(v interface{}) ([]byte, error) {
+	val, err := o.serialize(v)
+	if err == nil && o.noNullValues {
+		err = ValidateValueNoNull(val)
+	}
+	if err != nil || !o.latin1 {
+		return val, err
+	}
+	return toLatin1(val)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// WithLenientBOM tolerates and skips a UTF-8 byte order mark in front of the
+// png magic number, as left behind by some text transforms.  A warning is
+// reported through the handler set by `WithWarningHandler`.
+func WithLenientBOM() Option {
+	return func(o *options) {
+		o.lenientBOM = true
+	}
+}
+
+// WithWarningHandler registers `fn` to receive warnings about input that was
+// tolerated rather than rejected.
+func WithWarningHandler(fn func(msg string)) Option {
+	return func(o *options) {
+		o.warn = fn
+	}
+}
+
+// DuplicateStrategy selects which of several chunks sharing a keyword an
+// update applies to.
+type DuplicateStrategy int
+
+const (
+	// DuplicateFirst updates the first matching chunk in file order.  This is
+	// the default.
+	DuplicateFirst DuplicateStrategy = iota
+	// DuplicateLast updates the last matching chunk in file order.
+	DuplicateLast
+	// DuplicateAll updates every matching chunk.
+	DuplicateAll
+)
+
+// WithDuplicateStrategy selects which chunk `UpdateTEXT` rewrites when the
+// keyword appears more than once.
+func WithDuplicateStrategy(s DuplicateStrategy) Option {
+	return func(o *options) {
+		o.duplicates = s
+	}
+}
+
+// WithSoftware stamps the output with a `Software` tEXt chunk naming the
+// producing tool, in addition to the embedded key-value pair.  Any existing
+// `Software` tEXt chunks are replaced, so repeated runs leave exactly one.
+func WithSoftware(name string) Option {
+	return func(o *options) {
+		o.software = name
+	}
+}
+
+// WithTypeHints records the Go type of struct values next to them, in a
+// companion tEXt chunk keyed `<key>:__type`, so that `ExtractTyped` can
+// reconstruct them.  It is off by default.
+func WithTypeHints() Option {
+	return func(o *options) {
+		o.typeHints = true
+	}
+}
+
+// NewlineMode selects the line ending convention text values are normalized
+// to before embedding.
+type NewlineMode int
+
+const (
+	// NewlineAsIs leaves line endings untouched.  This is the default.
+	NewlineAsIs NewlineMode = iota
+	// NewlineLF rewrites all line endings to "\n", as the png specification
+	// recommends for text chunks.
+	NewlineLF
+	// NewlineCRLF rewrites all line endings to "\r\n".
+	NewlineCRLF
+)
+
+// normalize rewrites every "\r\n", "\r" and "\n" line ending in `val` to the
+// convention selected by `m`.
+func (m NewlineMode) normalize(val []byte) []byte {
+	if m == NewlineAsIs {
+		return val
+	}
+
+	nl := []byte("\n")
+	if m == NewlineCRLF {
+		nl = []byte("\r\n")
+	}
+
+	out := make([]byte, 0, len(val))
+	for i := 0; i < len(val); i++ {
+		switch val[i] {
+		case '\r':
+			if i+1 < len(val) && val[i+1] == '\n' {
+				i++
+			}
+			out = append(out, nl...)
+		case '\n':
+			out = append(out, nl...)
+		default:
+			out = append(out, val[i])
+		}
+	}
+	return out
+}
+
+// WithNewlineNormalization rewrites the line endings of text values to the
+// convention selected by `m` before embedding them.
+func WithNewlineNormalization(m NewlineMode) Option {
+	return func(o *options) {
+		o.newlines = m
+	}
+}
+
+// WithMaxChunks aborts extraction with `ErrTooManyChunks` once more than `n`
+// chunks have been seen, bounding the work done on adversarial input.  The
+// default is `DefaultMaxChunks`.
+func WithMaxChunks(n int) Option {
+	return func(o *options) {
+		o.maxChunks = n
+	}
+}
+
+// WithLatin1Transcode converts tEXt values from UTF-8 to Latin-1, the only
+// encoding the png specification allows in tEXt chunks.  Values containing
+// characters outside Latin-1 are rejected.  Read such values back with
+// `ExtractTEXTLatin1`.
+func WithLatin1Transcode() Option {
+	return func(o *options) {
+		o.latin1 = true
+	}
+}
+
+// WithNoJSONFallback makes embedding fail for values which are not an int,
+// uint, float, bool, string or []byte, rather than silently encoding them as
+// JSON.  This catches values of the wrong type being passed by mistake.  The
+// values accepted are stored exactly as without the option.
+func WithNoJSONFallback() Option {
+	return func(o *options) {
+		o.noJSON = true
+	}
+}
+
+// UTF8Mode selects how extraction treats iTXt text which is not valid UTF-8.
+type UTF8Mode int
+
+const (
+	// UTF8Raw returns the text bytes as stored.  This is the default.
+	UTF8Raw UTF8Mode = iota
+	// UTF8Lenient replaces each invalid UTF-8 sequence with the Unicode
+	// replacement character.
+	UTF8Lenient
+	// UTF8Strict rejects text which is not valid UTF-8 with an error.
+	UTF8Strict
+)
+
+// check applies the mode to the text stored under keyword `k`.
+func (m UTF8Mode) check(k string, text []byte) ([]byte, error) {
+	if m == UTF8Raw || utf8.Valid(text) {
+		return text, nil
+	}
+	if m == UTF8Strict {
+		return nil, fmt.Errorf("iTXt text for keyword (%s) is not valid UTF-8", k)
+	}
+	return bytes.ToValidUTF8(text, []byte(string(utf8.RuneError))), nil
+}
+
+// WithUTF8Validation selects how `ExtractITXT` handles uncompressed iTXt text
+// which is not valid UTF-8, as written by non-conformant producers.
+func WithUTF8Validation(m UTF8Mode) Option {
+	return func(o *options) {
+		o.utf8 = m
+	}
+}
+
+// WithAutoCompress lets `EmbedMulti` and `EmbedPairs` choose the chunk type of
+// each value: values of at least 1 KiB which compress are written as `zTXt`,
+// all others as `tEXt`.  If `report` is non-nil, the chosen chunk type is
+// recorded in it under each keyword.
+func WithAutoCompress(report map[string]string) Option {
+	return func(o *options) {
+		o.autoCompress = true
+		o.chunkTypes = report
+	}
+}
+
+// Placement selects where embedding injects new text chunks.
+type Placement int
+
+const (
+	// AfterIHDR injects new chunks right after the IHDR chunk (or PLTE, for
+	// the types which must follow it).  This is the default.
+	AfterIHDR Placement = iota
+	// GroupWithExistingText injects new text chunks right before the first
+	// existing text chunk, keeping the metadata contiguous.  Files without
+	// text chunks fall back to `AfterIHDR`.
+	GroupWithExistingText
+)
+
+// WithPlacement selects where new text chunks are injected.
+func WithPlacement(p Placement) Option {
+	return func(o *options) {
+		o.placement = p
+	}
+}
+
+// WithPreserveMtime makes `WriteFile` and `EditFile` restore the source
+// file's modification time onto the file they write, for build systems which
+// track changes by mtime.
+func WithPreserveMtime() Option {
+	return func(o *options) {
+		o.preserveMtime = true
+	}
+}
+
+// WithKeywordTruncation cuts keywords longer than the 79 bytes the png
+// specification allows down to size, rather than rejecting them.  Keywords of
+// iTXt chunks are cut on a UTF-8 rune boundary.  Each truncation is reported
+// through the handler set by `WithWarningHandler`.
+func WithKeywordTruncation() Option {
+	return func(o *options) {
+		o.truncateKeywords = true
+	}
+}
+
+// WithIHDRRepair makes embedding move the IHDR chunk to the front of files in
+// which broken producers placed other chunks before it, rather than failing
+// with `ErrIHDRNotFirst`.
+func WithIHDRRepair() Option {
+	return func(o *options) {
+		o.repairIHDR = true
+	}
+}
+
+// WithCodec encodes values which are not an int, uint, float or string with
+// `marshal` rather than as JSON, for compact encodings such as CBOR, msgpack
+// or protobuf.  Pair it with `WithUnmarshal` to decode them again.  Binary
+// encodings need a binary-safe chunk: tEXt and uncompressed iTXt chunks are
+// meant to hold text, so prefer `EmbedZTXT` or `EmbedITXTCompressed`, or
+// base64-encode the output.
+func WithCodec(marshal func(v interface{}) ([]byte, error)) Option {
+	return func(o *options) {
+		o.marshal = marshal
+	}
+}
+
+// WithUnmarshal decodes values with `unmarshal` rather than as JSON, in the
+// extractors which decode into a target, such as `ExtractAllInto` and
+// `ExtractInto`.  It is the counterpart of `WithCodec`.
+func WithUnmarshal(unmarshal func(data []byte, v interface{}) error) Option {
+	return func(o *options) {
+		o.unmarshal = unmarshal
+	}
+}
+
+// WithCompactSlices stores slices of the `SliceElem` types as their elements
+// separated by commas, rather than as JSON, which is considerably smaller for
+// large numeric arrays: []int{1, 2, 3} is stored as `1,2,3`.  Within string
+// elements, commas are escaped as `\,` and backslashes as `\\`.  Read such
+// values back with `GetSlice`.  An empty slice is stored as an empty value,
+// which `GetSlice` returns as an empty slice, so a slice holding just an
+// empty string does not round-trip.
+func WithCompactSlices() Option {
+	return func(o *options) {
+		o.compactSlices = true
+	}
+}
+
+// WithMetadataBudgetRatio sets the share of the compressed pixel data that
+// `SuggestedMetadataBudget` allows metadata to take up.  The default is
+// `DefaultMetadataBudgetRatio`.
+func WithMetadataBudgetRatio(ratio float64) Option {
+	return func(o *options) {
+		o.budgetRatio = ratio
+	}
+}
+
+// WithAutoPromoteUTF8 makes `EmbedTEXT`, `EmbedMulti` and `EmbedPairs` keep
+// tEXt chunks Latin-1 as the png specification requires, without rejecting
+// international text: values which Latin-1 can represent are transcoded as
+// under `WithLatin1Transcode`, and all other UTF-8 values are written to an
+// uncompressed iTXt chunk instead.  Without this option, `WithLatin1Transcode`
+// rejects such values.  Other embedders are unaffected.
+func WithAutoPromoteUTF8() Option {
+	return func(o *options) {
+		o.autoPromoteUTF8 = true
+	}
+}
+
+// WithNoNullValues rejects tEXt and zTXt values which contain a null byte, as
+// checked by `ValidateValueNoNull`.  The png specification forbids them, and
+// strict readers may truncate the value at the null.
+func WithNoNullValues() Option {
+	return func(o *options) {
+		o.noNullValues = true
+	}
+}
+
+// WithCaseInsensitiveKeys makes `ExtractAll` and `ExtractAllLimited` return
+// every keyword in lower case, merging keywords which differ only in case,
+// such as `Author` and `author`.  Keywords are Latin-1, so the accented
+// capitals are folded too, byte by byte.  As for duplicate keywords, the
+// record of the last chunk in file order wins.  `GetValue` then matches its
+// key case-insensitively too.  By default keywords are case-sensitive.
+func WithCaseInsensitiveKeys() Option {
+	return func(o *options) {
+		o.foldKeys = true
+	}
+}
@@ -0,0 +1,388 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestLenientBOM(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+	bom := append([]byte{0xef, 0xbb, 0xbf}, bs...)
+
+	// Strict mode rejects the BOM.
+	if _, err := EmbedTEXT(bom, "Key", "Value"); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+	if _, err := ExtractAll(bom); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+
+	warnings := []string{}
+	lenient := []Option{
+		WithLenientBOM(),
+		WithWarningHandler(func(msg string) { warnings = append(warnings, msg) }),
+	}
+
+	out, err := EmbedTEXT(bom, "Key", "Value", lenient...)
+	fatalIfError(t, err)
+	if !bytes.HasPrefix(out, pngMagic) {
+		t.Errorf("Expected output to start with the png magic\n")
+	}
+
+	m, err := ExtractTEXT(append([]byte{0xef, 0xbb, 0xbf}, out...), lenient...)
+	fatalIfError(t, err)
+	if string(m["Key"]) != "Value" {
+		t.Errorf("Expected `Value`, got %s\n", m["Key"])
+	}
+
+	if len(warnings) != 2 {
+		t.Errorf("Expected 2 warnings, got %d\n", len(warnings))
+	}
+}
+
+func TestLenientBOMExtractors(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+	out, err := EmbedTEXT(bs, "Key", "[1,2]")
+	fatalIfError(t, err)
+	out, err = EmbedTEXT(out, "List", []int{1, 2}, WithCompactSlices())
+	fatalIfError(t, err)
+	bom := append([]byte{0xef, 0xbb, 0xbf}, out...)
+
+	for name, extract := range map[string]func(data []byte, opts ...Option) error{
+		"ExtractTEXT": func(data []byte, opts ...Option) error {
+			_, err := ExtractTEXT(data, opts...)
+			return err
+		},
+		"ExtractITXT": func(data []byte, opts ...Option) error {
+			_, err := ExtractITXT(data, opts...)
+			return err
+		},
+		"ExtractZTXT": func(data []byte, opts ...Option) error {
+			_, err := ExtractZTXT(data, opts...)
+			return err
+		},
+		"ExtractAll": func(data []byte, opts ...Option) error {
+			_, err := ExtractAll(data, opts...)
+			return err
+		},
+		"ExtractAllDecoded": func(data []byte, opts ...Option) error {
+			_, err := ExtractAllDecoded(data, opts...)
+			return err
+		},
+		"ExtractAllValidated": func(data []byte, opts ...Option) error {
+			_, err := ExtractAllValidated(data, opts...)
+			return err
+		},
+		"ExtractAllInto": func(data []byte, opts ...Option) error {
+			return ExtractAllInto(data, map[string]interface{}{"Key": &[]int{}}, opts...)
+		},
+		"ExtractTEXTFast": func(data []byte, opts ...Option) error {
+			_, err := ExtractTEXTFast(data, opts...)
+			return err
+		},
+		"ExtractTEXTLatin1": func(data []byte, opts ...Option) error {
+			_, err := ExtractTEXTLatin1(data, opts...)
+			return err
+		},
+		"ExtractITXTFull": func(data []byte, opts ...Option) error {
+			_, err := ExtractITXTFull(data, opts...)
+			return err
+		},
+		"ExtractITXTLocalized": func(data []byte, opts ...Option) error {
+			_, err := ExtractITXTLocalized(data, opts...)
+			return err
+		},
+		"ExtractPrefix": func(data []byte, opts ...Option) error {
+			_, err := ExtractPrefix(data, "K", opts...)
+			return err
+		},
+		"ExtractByType": func(data []byte, opts ...Option) error {
+			_, _, _, err := ExtractByType(data, opts...)
+			return err
+		},
+		"DuplicateKeywords": func(data []byte, opts ...Option) error {
+			_, err := DuplicateKeywords(data, opts...)
+			return err
+		},
+		"GetValue": func(data []byte, opts ...Option) error {
+			_, _, err := GetValue(data, "Key", opts...)
+			return err
+		},
+		"HasKey": func(data []byte, opts ...Option) error {
+			_, err := HasKey(data, "Key", opts...)
+			return err
+		},
+		"ExtractInto": func(data []byte, opts ...Option) error {
+			_, err := ExtractInto[[]int](data, "Key", opts...)
+			return err
+		},
+		"ExtractFlattened": func(data []byte, opts ...Option) error {
+			_, err := ExtractFlattened(data, "Key", opts...)
+			return err
+		},
+		"GetSlice": func(data []byte, opts ...Option) error {
+			_, err := GetSlice[int](data, "List", opts...)
+			return err
+		},
+		"TextStats": func(data []byte, opts ...Option) error {
+			_, err := TextStats(data, opts...)
+			return err
+		},
+		"SuggestedMetadataBudget": func(data []byte, opts ...Option) error {
+			_, err := SuggestedMetadataBudget(data, opts...)
+			return err
+		},
+	} {
+		if err := extract(bom); err == nil {
+			t.Errorf("%s: expected error, got nil!\n", name)
+		}
+		if err := extract(bom, WithLenientBOM()); err != nil {
+			t.Errorf("%s: expected the BOM to be skipped, got %v\n", name, err)
+		}
+	}
+}
+
+func TestWithSoftware(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXT(bs, "Key0", "Value0", WithSoftware("pipeline v1"))
+	fatalIfError(t, err)
+	out, err = EmbedITXT(out, "Key1", "Value1", WithSoftware("pipeline v2"))
+	fatalIfError(t, err)
+
+	act := textValues(t, out, "Software")
+	if len(act) != 1 || act[0] != "pipeline v2" {
+		t.Errorf("Expected a single `pipeline v2` Software chunk, got %v\n", act)
+	}
+
+	m, err := ExtractAll(out)
+	fatalIfError(t, err)
+	if len(m) != 3 {
+		t.Errorf("Expected 3 keys, got %d\n", len(m))
+	}
+}
+
+func TestWithNewlineNormalization(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	comment := "line one\r\nline two\rline three\nend"
+	for _, tc := range []struct {
+		opts []Option
+		exp  string
+	}{
+		{opts: nil, exp: comment},
+		{opts: []Option{WithNewlineNormalization(NewlineAsIs)}, exp: comment},
+		{opts: []Option{WithNewlineNormalization(NewlineLF)}, exp: "line one\nline two\nline three\nend"},
+		{opts: []Option{WithNewlineNormalization(NewlineCRLF)}, exp: "line one\r\nline two\r\nline three\r\nend"},
+	} {
+		for _, embed := range []func([]byte, string, interface{}, ...Option) ([]byte, error){
+			EmbedTEXT, EmbedITXT,
+		} {
+			out, err := embed(bs, "Comment", comment, tc.opts...)
+			fatalIfError(t, err)
+
+			m, err := ExtractAll(out)
+			fatalIfError(t, err)
+			if string(m["Comment"]) != tc.exp {
+				t.Errorf("Expected %q, got %q\n", tc.exp, m["Comment"])
+			}
+		}
+	}
+}
+
+func TestWithMaxChunks(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+	bs, err = EmbedTEXT(bs, "Key", "Value")
+	fatalIfError(t, err)
+
+	// Craft a file with more tiny chunks than the default cap.
+	chunks, err := scanChunks(bs)
+	fatalIfError(t, err)
+	gama, err := buildChunk("gAMA", []byte{0, 0, 0xb1, 0x8f})
+	fatalIfError(t, err)
+
+	buf := bytes.NewBuffer(nil)
+	buf.Write(bs[:chunks[0].end()])
+	for i := 0; i < DefaultMaxChunks; i++ {
+		buf.Write(gama)
+	}
+	buf.Write(bs[chunks[0].end():])
+	bomb := buf.Bytes()
+
+	for _, extract := range []func([]byte, ...Option) (map[string][]byte, error){
+		ExtractTEXT, ExtractITXT, ExtractAll,
+	} {
+		if _, err := extract(bomb); !errors.Is(err, ErrTooManyChunks) {
+			t.Errorf("Expected ErrTooManyChunks, got %v\n", err)
+		}
+		if _, err := extract(bs, WithMaxChunks(2)); !errors.Is(err, ErrTooManyChunks) {
+			t.Errorf("Expected ErrTooManyChunks, got %v\n", err)
+		}
+
+		_, err := extract(bomb, WithMaxChunks(DefaultMaxChunks+4))
+		fatalIfError(t, err)
+	}
+
+	// The cap covers the other extractors too.
+	for name, extract := range map[string]func(data []byte, opts ...Option) error{
+		"GetValue": func(data []byte, opts ...Option) error {
+			_, _, err := GetValue(data, "Key", opts...)
+			return err
+		},
+		"HasKey": func(data []byte, opts ...Option) error {
+			_, err := HasKey(data, "Key", opts...)
+			return err
+		},
+		"ExtractByType": func(data []byte, opts ...Option) error {
+			_, _, _, err := ExtractByType(data, opts...)
+			return err
+		},
+		"TextStats": func(data []byte, opts ...Option) error {
+			_, err := TextStats(data, opts...)
+			return err
+		},
+		"ExtractPrefix": func(data []byte, opts ...Option) error {
+			_, err := ExtractPrefix(data, "K", opts...)
+			return err
+		},
+		"DuplicateKeywords": func(data []byte, opts ...Option) error {
+			_, err := DuplicateKeywords(data, opts...)
+			return err
+		},
+	} {
+		if err := extract(bomb); !errors.Is(err, ErrTooManyChunks) {
+			t.Errorf("%s: expected ErrTooManyChunks, got %v\n", name, err)
+		}
+		if err := extract(bs, WithMaxChunks(2)); !errors.Is(err, ErrTooManyChunks) {
+			t.Errorf("%s: expected ErrTooManyChunks, got %v\n", name, err)
+		}
+		fatalIfError(t, extract(bomb, WithMaxChunks(DefaultMaxChunks+4)))
+	}
+}
+
+func TestWithNoJSONFallback(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	v := struct{ A int }{1}
+	_, err = EmbedTEXT(bs, "Key", v)
+	fatalIfError(t, err)
+	if _, err := EmbedTEXT(bs, "Key", v, WithNoJSONFallback()); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+	if _, err := EmbedITXT(bs, "Key", map[string]int{}, WithNoJSONFallback()); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+
+	for _, tc := range []struct {
+		v   interface{}
+		exp string
+	}{
+		{v: 42, exp: "42"},
+		{v: "str", exp: "str"},
+		{v: true, exp: "true"},
+		{v: []byte("raw"), exp: `"cmF3"`},
+	} {
+		out, err := EmbedTEXT(bs, "Key", tc.v, WithNoJSONFallback())
+		fatalIfError(t, err)
+		m, err := ExtractTEXT(out)
+		fatalIfError(t, err)
+		if string(m["Key"]) != tc.exp {
+			t.Errorf("Expected %s, got %s\n", tc.exp, m["Key"])
+		}
+
+		// The option does not change the encoding of accepted values.
+		def, err := EmbedTEXT(bs, "Key", tc.v)
+		fatalIfError(t, err)
+		if !bytes.Equal(out, def) {
+			t.Errorf("Expected the default encoding for %T\n", tc.v)
+		}
+	}
+}
+
+func TestWithUTF8Validation(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedITXT(bs, "Bad", string([]byte{'a', 0xff, 'b'}))
+	fatalIfError(t, err)
+
+	for _, tc := range []struct {
+		mode  UTF8Mode
+		exp   string
+		isErr bool
+	}{
+		// Negative test cases.
+		{mode: UTF8Strict, isErr: true},
+
+		// Positive test cases.
+		{mode: UTF8Raw, exp: "a\xffb"},
+		{mode: UTF8Lenient, exp: "a�b"},
+	} {
+		m, err := ExtractITXT(out, WithUTF8Validation(tc.mode))
+		if tc.isErr {
+			if err == nil {
+				t.Errorf("Expected error, got nil!\n")
+			}
+			continue
+		}
+		fatalIfError(t, err)
+		if string(m["Bad"]) != tc.exp {
+			t.Errorf("Expected %q, got %q\n", tc.exp, m["Bad"])
+		}
+	}
+}
+
+func TestWithCodec(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	marshal := func(v interface{}) ([]byte, error) {
+		buf := &bytes.Buffer{}
+		err := gob.NewEncoder(buf).Encode(v)
+		return buf.Bytes(), err
+	}
+	unmarshal := func(data []byte, v interface{}) error {
+		return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+	}
+
+	in := hintedPoint{X: 3, Y: 4}
+	out, err := EmbedITXTCompressed(bs, "Point", in, WithCodec(marshal))
+	fatalIfError(t, err)
+	out, err = EmbedITXTCompressed(out, "Name", "plain", WithCodec(marshal))
+	fatalIfError(t, err)
+
+	p, err := ExtractInto[hintedPoint](out, "Point", WithUnmarshal(unmarshal))
+	fatalIfError(t, err)
+	if p != in {
+		t.Errorf("Expected %+v, got %+v\n", in, p)
+	}
+
+	var q hintedPoint
+	fatalIfError(t, ExtractAllInto(out, map[string]interface{}{"Point": &q}, WithUnmarshal(unmarshal)))
+	if q != in {
+		t.Errorf("Expected %+v, got %+v\n", in, q)
+	}
+
+	// Scalars bypass the codec.
+	if v, _, _ := GetValue(out, "Name"); string(v) != "plain" {
+		t.Errorf("Expected plain, got %q\n", v)
+	}
+
+	// Without the unmarshal option, the value is not JSON.
+	if _, err := ExtractInto[hintedPoint](out, "Point"); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
@@ -0,0 +1,95 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// OrderSuffix is appended to a keyword to form the keyword of the companion
+// tEXt chunk holding the order hint written by `EmbedTEXTOrdered`.  The hint
+// is stored as a decimal integer: the text under `Title` with hint 2 is
+// accompanied by a tEXt chunk keyed `Title:__order` holding "2".
+const OrderSuffix = ":__order"
+
+////////////////////////////////////////////////////////////////////////////////
+
+// EmbedTEXTOrdered is like `EmbedTEXT` but also records `order` as the order
+// hint of `k`, replacing any previous hint.  `NormalizeChunks` sorts the text
+// chunks by these hints.
+func EmbedTEXTOrdered(data []byte, k string, v interface{}, order int, opts ...Option) ([]byte, error) {
+	out, err := EmbedTEXT(data, k, v, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return replaceTEXT(out, [2]string{k + OrderSuffix, strconv.Itoa(order)})
+}
+
+// NormalizeChunks reorders the text chunks of the PNG data by the order hints
+// written by `EmbedTEXTOrdered`, lowest first, so the final chunk order does
+// not depend on the order the keys were embedded in.  A hint chunk follows
+// the chunks of its key.  Text chunks without a hint come after all hinted
+// ones, in their original order.  The sorted chunks take up the positions
+// text chunks held before, so all other chunks stay in place.
+func NormalizeChunks(data []byte) ([]byte, error) {
+	chunks, err := scanChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	orders := map[string]int{}
+	slots := []int{}
+	for i, c := range chunks {
+		if _, ok := c.keyword(data); !ok {
+			continue
+		}
+		slots = append(slots, i)
+		if c.ct != "tEXt" {
+			continue
+		}
+		k, v, err := parseTEXT(c.data(data))
+		if err != nil || !strings.HasSuffix(k, OrderSuffix) {
+			continue
+		}
+		if orders[strings.TrimSuffix(k, OrderSuffix)], err = strconv.Atoi(string(v)); err != nil {
+			return nil, fmt.Errorf("keyword (%s): invalid order hint: %w", k, err)
+		}
+	}
+
+	// rank returns the order hint of the chunk at index i, whether it has
+	// one, and whether it is a hint chunk itself.
+	rank := func(i int) (int, bool, bool) {
+		k, _ := chunks[i].keyword(data)
+		base := strings.TrimSuffix(k, OrderSuffix)
+		order, ok := orders[base]
+		return order, ok, base != k
+	}
+	sorted := append([]int{}, slots...)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		oa, hintedA, hintA := rank(sorted[a])
+		ob, hintedB, hintB := rank(sorted[b])
+		if hintedA != hintedB {
+			return hintedA
+		}
+		if oa != ob {
+			return oa < ob
+		}
+		return !hintA && hintB
+	})
+
+	moved := map[int]int{}
+	for n, i := range slots {
+		moved[i] = sorted[n]
+	}
+	return rewriteChunks(data, chunks, func(i int, c chunk) []byte {
+		if j, ok := moved[i]; ok {
+			return chunks[j].raw(data)
+		}
+		return c.raw(data)
+	}), nil
+}
@@ -0,0 +1,65 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// textKeywords returns the keywords of the text chunks of `data` in file
+// order.
+func textKeywords(t *testing.T, data []byte) []string {
+	chunks, err := scanChunks(data)
+	fatalIfError(t, err)
+
+	ret := []string{}
+	for _, c := range chunks {
+		if k, ok := c.keyword(data); ok {
+			ret = append(ret, k)
+		}
+	}
+	return ret
+}
+
+func TestNormalizeChunks(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXTOrdered(bs, "Second", "b", 2)
+	fatalIfError(t, err)
+	out, err = EmbedTEXT(out, "Unordered", "x")
+	fatalIfError(t, err)
+	out, err = EmbedTEXTOrdered(out, "Third", "c", 3)
+	fatalIfError(t, err)
+	out, err = EmbedTEXTOrdered(out, "First", "a", 1)
+	fatalIfError(t, err)
+
+	norm, err := NormalizeChunks(out)
+	fatalIfError(t, err)
+
+	exp := "First,First:__order,Second,Second:__order,Third,Third:__order,Unordered"
+	if act := strings.Join(textKeywords(t, norm), ","); act != exp {
+		t.Errorf("Expected %s, got %s\n", exp, act)
+	}
+	if act := strings.Join(chunkTypes(t, norm), ","); act != strings.Join(chunkTypes(t, out), ",") {
+		t.Errorf("Expected the chunk types in place, got %s\n", act)
+	}
+
+	// Normalizing is idempotent.
+	again, err := NormalizeChunks(norm)
+	fatalIfError(t, err)
+	if string(again) != string(norm) {
+		t.Errorf("Expected a second normalization to change nothing\n")
+	}
+
+	// Negative test cases.
+	bad, err := EmbedTEXT(bs, "Key"+OrderSuffix, "first")
+	fatalIfError(t, err)
+	if _, err := NormalizeChunks(bad); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
@@ -0,0 +1,153 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/sabhiram/pngr"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// eachReaderChunk calls `fn` with every chunk of type `ct` yielded by `r`.
+// All chunks yielded count towards the chunk limit of `o`.
+func eachReaderChunk(r *pngr.Reader, ct string, o *options, fn func(c *pngr.Chunk) error) error {
+	n := 0
+	c, err := r.Next()
+	for ; err == nil; c, err = r.Next() {
+		if n++; n > o.maxChunks {
+			return fmt.Errorf("%w: limit is %d", ErrTooManyChunks, o.maxChunks)
+		}
+		if c.ChunkType != ct {
+			continue
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return err
+}
+
+// skipKeyword reports whether a record of chunk type `ct` should be left out of
+// an extracted map because its keyword `k` is empty, which the png
+// specification forbids.  Skipped records are reported as warnings.
+func (o *options) skipKeyword(ct, k string) bool {
+	if k != "" {
+		return false
+	}
+	o.warnf("skipped %s chunk with an empty keyword", ct)
+	return true
+}
+
+// addTEXT adds the record held by the tEXt chunk data `d` to `ret`.  Chunks
+// without a null separator are skipped, as are those with an empty keyword.
+func (o *options) addTEXT(ret map[string][]byte, d []byte) {
+	pt := bytes.IndexByte(d, NULL_SEPERATOR)
+	if pt >= 0 && !o.skipKeyword(`tEXt`, string(d[:pt])) {
+		ret[string(d[:pt])] = d[pt+1:]
+	}
+}
+
+func extractTEXTFromReader(r *pngr.Reader, o *options) (map[string][]byte, error) {
+	ret := map[string][]byte{}
+	err := eachReaderChunk(r, `tEXt`, o, func(c *pngr.Chunk) error {
+		o.addTEXT(ret, c.Data)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func extractITXTFromReader(r *pngr.Reader, o *options) (map[string][]byte, error) {
+	ret := map[string][]byte{}
+	err := eachReaderChunk(r, `iTXt`, o, func(c *pngr.Chunk) error {
+		rec, err := parseITXTRecord(c.Data)
+		if err != nil {
+			return err
+		}
+		if o.skipKeyword(c.ChunkType, rec.keyword) {
+			return nil
+		}
+		text := rec.text
+		if rec.compressionFlag == 0 {
+			if text, err = o.utf8.check(rec.keyword, text); err != nil {
+				return err
+			}
+		}
+		ret[rec.keyword] = text
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func extractZTXTFromReader(r *pngr.Reader, o *options) (map[string][]byte, error) {
+	ret := map[string][]byte{}
+	err := eachReaderChunk(r, `zTXt`, o, func(c *pngr.Chunk) error {
+		keyword, _, z, err := parseZTXT(c.Data)
+		if err != nil {
+			return err
+		}
+		if o.skipKeyword(c.ChunkType, keyword) {
+			return nil
+		}
+		text, err := inflate(z)
+		if err != nil {
+			return err
+		}
+		ret[keyword] = text
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ExtractTEXTFromReader is like `ExtractTEXT` but consumes the chunks of an
+// existing `pngr.Reader`, for callers which already parse with `pngr`.  The
+// reader must have been created without a chunk type filter, or with one that
+// includes `tEXt`.
+func ExtractTEXTFromReader(r *pngr.Reader, opts ...Option) (map[string][]byte, error) {
+	return extractTEXTFromReader(r, newOptions(opts))
+}
+
+// ExtractITXTFromReader is like `ExtractITXT` but consumes the chunks of an
+// existing `pngr.Reader`.
+func ExtractITXTFromReader(r *pngr.Reader, opts ...Option) (map[string][]byte, error) {
+	return extractITXTFromReader(r, newOptions(opts))
+}
+
+// ExtractZTXTFromReader is like `ExtractZTXT` but consumes the chunks of an
+// existing `pngr.Reader`.
+func ExtractZTXTFromReader(r *pngr.Reader, opts ...Option) (map[string][]byte, error) {
+	return extractZTXTFromReader(r, newOptions(opts))
+}
+
+// ExtractZTXT processes a stream of raw PNG data, and returns a map of the
+// inflated text of all `zTXt` records.
+func ExtractZTXT(data []byte, opts ...Option) (map[string][]byte, error) {
+	o := newOptions(opts)
+	data = o.stripBOM(data)
+	if err := checkSignature(data); err != nil {
+		return nil, err
+	}
+
+	r, err := pngr.NewReader(data, nil)
+	if err != nil {
+		return nil, err
+	}
+	return extractZTXTFromReader(r, o)
+}
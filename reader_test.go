@@ -0,0 +1,55 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/sabhiram/pngr"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestExtractFromReader(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXT(bs, "Text", "TextValue")
+	fatalIfError(t, err)
+	out, err = EmbedITXT(out, "Intl", "IntlValue")
+	fatalIfError(t, err)
+	out, err = EmbedChunk(out, "zTXt", zTXtChunkData(t, "Packed", []byte("PackedValue")))
+	fatalIfError(t, err)
+
+	for _, tc := range []struct {
+		extract func(*pngr.Reader, ...Option) (map[string][]byte, error)
+		opts    *pngr.ReaderOptions
+		k, v    string
+	}{
+		{extract: ExtractTEXTFromReader, opts: nil, k: "Text", v: "TextValue"},
+		{extract: ExtractITXTFromReader, opts: nil, k: "Intl", v: "IntlValue"},
+		{extract: ExtractZTXTFromReader, opts: nil, k: "Packed", v: "PackedValue"},
+		{
+			extract: ExtractTEXTFromReader,
+			opts:    &pngr.ReaderOptions{IncludedChunkTypes: []string{"tEXt"}},
+			k:       "Text",
+			v:       "TextValue",
+		},
+	} {
+		r, err := pngr.NewReader(out, tc.opts)
+		fatalIfError(t, err)
+
+		m, err := tc.extract(r)
+		fatalIfError(t, err)
+		if len(m) != 1 || string(m[tc.k]) != tc.v {
+			t.Errorf("Expected {%s: %s}, got %v\n", tc.k, tc.v, m)
+		}
+	}
+
+	m, err := ExtractZTXT(out)
+	fatalIfError(t, err)
+	if string(m["Packed"]) != "PackedValue" {
+		t.Errorf("Expected PackedValue, got %s\n", m["Packed"])
+	}
+}
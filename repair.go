@@ -0,0 +1,61 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"fmt"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Repair salvages a partially corrupt png by rebuilding it from its valid
+// chunks.  Chunks with a CRC mismatch are dropped, as is a final chunk cut
+// short by the end of the data, any IHDR after the first and anything
+// following IEND.  The survivors are reassembled with IHDR first and a fresh
+// IEND last.  Every dropped chunk is reported in the returned slice of errors,
+// which wrap `ErrCRCMismatch`, `ErrChunkTruncated`, `ErrDuplicateIHDR` or
+// `ErrTrailingData`.  An error is returned if no valid IHDR survives, as
+// nothing can be salvaged without one.  Dropping IDAT or PLTE chunks leaves
+// the image undecodable, so check the result.
+func Repair(data []byte) ([]byte, []error, error) {
+	chunks, err := scanChunks(data)
+	if err != nil && !errors.Is(err, ErrChunkTruncated) {
+		return nil, nil, err
+	}
+
+	dropped := []error{}
+	if err != nil {
+		dropped = append(dropped, err)
+	} else if n := trailingBytes(data, chunks); n > 0 {
+		dropped = append(dropped, fmt.Errorf("%w: %d bytes", ErrTrailingData, n))
+	}
+
+	var ihdr []byte
+	body := []byte{}
+	for _, c := range chunks {
+		if !c.crcValid(data) {
+			dropped = append(dropped, fmt.Errorf("%w: chunk %s at offset %d", ErrCRCMismatch, c.ct, c.offset))
+			continue
+		}
+		switch {
+		case c.ct == "IHDR" && ihdr == nil:
+			ihdr = c.raw(data)
+		case c.ct == "IHDR":
+			dropped = append(dropped, fmt.Errorf("%w: at offset %d", ErrDuplicateIHDR, c.offset))
+		case c.ct == "IEND":
+		default:
+			body = append(body, c.raw(data)...)
+		}
+	}
+	if ihdr == nil {
+		return nil, dropped, errors.New("no valid IHDR chunk to repair from")
+	}
+
+	iend, _ := buildChunk("IEND", nil)
+	out := make([]byte, 0, len(pngMagic)+len(ihdr)+len(body)+len(iend))
+	out = append(out, pngMagic...)
+	out = append(out, ihdr...)
+	out = append(out, body...)
+	return append(out, iend...), dropped, nil
+}
@@ -0,0 +1,76 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestRepair(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXT(bs, "Good", "g")
+	fatalIfError(t, err)
+	out, err = EmbedTEXT(out, "Bad", "b")
+	fatalIfError(t, err)
+
+	// Corrupt the CRC of the Bad chunk, and append junk after IEND.
+	chunks, err := scanChunks(out)
+	fatalIfError(t, err)
+	out[chunks[1].end()-1] ^= 0xff
+	out = append(out, "junk"...)
+
+	fixed, dropped, err := Repair(out)
+	fatalIfError(t, err)
+
+	if len(dropped) != 2 || !errors.Is(dropped[0], ErrTrailingData) || !errors.Is(dropped[1], ErrCRCMismatch) {
+		t.Errorf("Unexpected dropped chunks %v\n", dropped)
+	}
+	exp := "IHDR,tEXt,IDAT,IEND"
+	if cts := chunkTypes(t, fixed); strings.Join(cts, ",") != exp {
+		t.Errorf("Expected %s, got %v\n", exp, cts)
+	}
+	if m, _ := ExtractAll(fixed); len(m) != 1 || string(m["Good"]) != "g" {
+		t.Errorf("Expected only Good to survive, got %v\n", m)
+	}
+	if _, err := png.Decode(bytes.NewReader(fixed)); err != nil {
+		t.Errorf("Expected a decodable png, got %v\n", err)
+	}
+
+	// A file cut short loses its last chunk, and gains a fresh IEND.
+	fixed, dropped, err = Repair(bs[:len(bs)-6])
+	fatalIfError(t, err)
+	if len(dropped) != 1 || !errors.Is(dropped[0], ErrChunkTruncated) {
+		t.Errorf("Unexpected dropped chunks %v\n", dropped)
+	}
+	if !bytes.Equal(fixed, bs) {
+		t.Errorf("Expected the original file back\n")
+	}
+
+	// IHDR chunks after the first are dropped.
+	ihdr := bs[8+8 : 8+8+13]
+	dup := withRawChunk(t, withRawChunk(t, bs, "IHDR", ihdr), "IHDR", ihdr)
+	fixed, dropped, err = Repair(dup)
+	fatalIfError(t, err)
+	if len(dropped) != 2 || !errors.Is(dropped[0], ErrDuplicateIHDR) || !errors.Is(dropped[1], ErrDuplicateIHDR) {
+		t.Errorf("Unexpected dropped chunks %v\n", dropped)
+	}
+	if !bytes.Equal(fixed, bs) {
+		t.Errorf("Expected the original file back\n")
+	}
+
+	// Negative test cases.
+	bad := append([]byte{}, bs...)
+	bad[8+8] ^= 0xff
+	if _, _, err := Repair(bad); err == nil {
+		t.Errorf("Expected error without a valid IHDR, got nil!\n")
+	}
+}
@@ -0,0 +1,68 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// HMACSuffix is appended to a keyword to form the keyword of the companion
+// chunk holding the signature written by `EmbedSigned`.
+const HMACSuffix = ":__hmac"
+
+////////////////////////////////////////////////////////////////////////////////
+
+// signature returns the HMAC-SHA256 of keyword `k` and value `val` under
+// `secret`.  The keyword is null terminated, as in the chunk, so that moving
+// bytes between keyword and value changes the signature.
+func signature(k string, val, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(k))
+	mac.Write([]byte{NULL_SEPERATOR})
+	mac.Write(val)
+	return mac.Sum(nil)
+}
+
+// EmbedSigned embeds `v` under `k` in a tEXt chunk, as `EmbedTEXT` does, along
+// with a hex encoded HMAC-SHA256 over the keyword and value in a companion
+// tEXt chunk keyed `<k>:__hmac`.  Existing tEXt chunks with either keyword are
+// replaced.  Check the value with `VerifySigned`.
+func EmbedSigned(data []byte, k string, v interface{}, secret []byte) ([]byte, error) {
+	val, err := newOptions(nil).serializeTEXT(v)
+	if err != nil {
+		return nil, err
+	}
+	mac := hex.EncodeToString(signature(k, val, secret))
+	return replaceTEXT(data, [2]string{k, string(val)}, [2]string{k + HMACSuffix, mac})
+}
+
+// VerifySigned returns the value stored under `k` by `EmbedSigned`, and
+// whether its signature matches under `secret`.  The signatures are compared
+// in constant time.  An error is returned if either the value or its
+// signature is missing.
+func VerifySigned(data []byte, k string, secret []byte) (bool, []byte, error) {
+	kvs, err := ExtractAll(data)
+	if err != nil {
+		return false, nil, err
+	}
+
+	val, found := kvs[k]
+	if !found {
+		return false, nil, fmt.Errorf("keyword (%s) not found", k)
+	}
+	stored, found := kvs[k+HMACSuffix]
+	if !found {
+		return false, nil, fmt.Errorf("signature keyword (%s) not found", k+HMACSuffix)
+	}
+
+	mac, err := hex.DecodeString(string(stored))
+	if err != nil {
+		return false, val, nil
+	}
+	return hmac.Equal(mac, signature(k, val, secret)), val, nil
+}
@@ -0,0 +1,64 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestEmbedSigned(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+	secret := []byte("s3cret")
+
+	signed, err := EmbedSigned(bs, "owner", "alice", secret)
+	fatalIfError(t, err)
+
+	// Positive test cases.
+	ok, val, err := VerifySigned(signed, "owner", secret)
+	fatalIfError(t, err)
+	if !ok || string(val) != "alice" {
+		t.Errorf("Expected a valid signature on alice, got %v %s\n", ok, val)
+	}
+
+	// Re-signing replaces both chunks.
+	resigned, err := EmbedSigned(signed, "owner", "bob", secret)
+	fatalIfError(t, err)
+	if n := len(textValues(t, resigned, "owner")) + len(textValues(t, resigned, "owner"+HMACSuffix)); n != 2 {
+		t.Errorf("Expected 2 chunks after re-signing, got %d\n", n)
+	}
+	if ok, _, _ := VerifySigned(resigned, "owner", secret); !ok {
+		t.Errorf("Expected the re-signed value to verify\n")
+	}
+
+	// Negative test cases.
+	tampered, err := replaceTEXT(signed, [2]string{"owner", "mallory"})
+	fatalIfError(t, err)
+	garbled, err := replaceTEXT(signed, [2]string{"owner" + HMACSuffix, "not hex"})
+	fatalIfError(t, err)
+	for _, tc := range []struct {
+		data   []byte
+		secret []byte
+	}{
+		{data: tampered, secret: secret},
+		{data: garbled, secret: secret},
+		{data: signed, secret: []byte("wrong")},
+	} {
+		ok, _, err := VerifySigned(tc.data, "owner", tc.secret)
+		fatalIfError(t, err)
+		if ok {
+			t.Errorf("Expected the signature check to fail\n")
+		}
+	}
+
+	unsigned, err := EmbedTEXT(bs, "owner", "alice")
+	fatalIfError(t, err)
+	for _, data := range [][]byte{bs, unsigned} {
+		if _, _, err := VerifySigned(data, "owner", secret); err == nil {
+			t.Errorf("Expected error, got nil!\n")
+		}
+	}
+}
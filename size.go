@@ -0,0 +1,83 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+// chunkFraming is the number of bytes every chunk spends on its length, type
+// and CRC fields.
+const chunkFraming = 12
+
+// DefaultMetadataBudgetRatio is the share of the compressed pixel data that
+// `SuggestedMetadataBudget` allows metadata to take up, unless overridden
+// with `WithMetadataBudgetRatio`.
+const DefaultMetadataBudgetRatio = 0.1
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ChunkOverhead returns the number of bytes a chunk of type `chunkType` with
+// keyword `keyword` adds to a file beyond its value:
+//
+//	all chunks: 12 bytes of length, type and CRC framing
+//	tEXt:       the keyword and its null separator
+//	zTXt:       as tEXt, plus the compression method byte
+//	iTXt:       as zTXt, plus the compression flag byte and the null
+//	            separators of the (empty) language tag and translated keyword
+//
+// For chunk types other than these three, only the framing is counted and the
+// keyword is ignored.
+func ChunkOverhead(keyword string, chunkType string) int {
+	switch chunkType {
+	case "tEXt":
+		return chunkFraming + len(keyword) + 1
+	case "zTXt":
+		return chunkFraming + len(keyword) + 2
+	case "iTXt":
+		return chunkFraming + len(keyword) + 5
+	}
+	return chunkFraming
+}
+
+// MetadataOverhead returns the total number of bytes the `tEXt`, `iTXt` and
+// `zTXt` chunks of the PNG data occupy, framing included, so the share of the
+// file spent on metadata rather than pixels can be audited.
+func MetadataOverhead(data []byte) (int, error) {
+	cis, err := ListChunks(data, "tEXt", "iTXt", "zTXt")
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, ci := range cis {
+		n += chunkFraming + ci.Length
+	}
+	return n, nil
+}
+
+// IDATSize returns the total length of the IDAT chunks of the PNG data: the
+// size of the compressed pixel stream, measured without inflating it.
+func IDATSize(data []byte) (int, error) {
+	cis, err := ListChunks(data, "IDAT")
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, ci := range cis {
+		n += ci.Length
+	}
+	return n, nil
+}
+
+// SuggestedMetadataBudget returns a recommended maximum for the number of
+// bytes spent on metadata, as measured by `MetadataOverhead`, so that large
+// metadata does not bloat small images such as thumbnails.  It is the
+// `IDATSize` of the PNG data times `DefaultMetadataBudgetRatio`, or the ratio
+// set by `WithMetadataBudgetRatio`, rounded down.  This is a heuristic, not a
+// limit the embedders enforce.
+func SuggestedMetadataBudget(data []byte, opts ...Option) (int, error) {
+	o := newOptions(opts)
+	n, err := IDATSize(o.stripBOM(data))
+	if err != nil {
+		return 0, err
+	}
+	return int(float64(n) * o.budgetRatio), nil
+}
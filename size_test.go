@@ -0,0 +1,155 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"crypto/rand"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestChunkOverhead(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	z := deflate([]byte{})
+	zOut, err := embed(bs, buildChunkT(t, "zTXt", formatZTXTChunk(z, "Key")))
+	fatalIfError(t, err)
+
+	tOut, err := EmbedTEXT(bs, "Key", "")
+	fatalIfError(t, err)
+	iOut, err := EmbedITXT(bs, "Key", "")
+	fatalIfError(t, err)
+
+	for _, tc := range []struct {
+		ct    string
+		out   []byte
+		value int
+	}{
+		{ct: "tEXt", out: tOut},
+		{ct: "iTXt", out: iOut},
+		{ct: "zTXt", out: zOut, value: len(z)},
+	} {
+		exp := len(tc.out) - len(bs) - tc.value
+		if got := ChunkOverhead("Key", tc.ct); got != exp {
+			t.Errorf("Expected %d bytes of %s overhead, got %d\n", exp, tc.ct, got)
+		}
+	}
+
+	if got := ChunkOverhead("Key", "gAMA"); got != 12 {
+		t.Errorf("Expected 12 bytes of gAMA overhead, got %d\n", got)
+	}
+}
+
+// buildChunkT is `buildChunk` failing the test on error.
+func buildChunkT(t *testing.T, ct string, data []byte) []byte {
+	c, err := buildChunk(ct, data)
+	fatalIfError(t, err)
+	return c
+}
+
+func TestMetadataOverhead(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	n, err := MetadataOverhead(bs)
+	fatalIfError(t, err)
+	if n != 0 {
+		t.Errorf("Expected no overhead, got %d\n", n)
+	}
+
+	out, err := EmbedTEXT(bs, "Key0", "Value0")
+	fatalIfError(t, err)
+	out, err = EmbedITXT(out, "Key1", "Value1")
+	fatalIfError(t, err)
+	out, err = EmbedChunk(out, "gAMA", []byte{0, 0, 0xb1, 0x8f})
+	fatalIfError(t, err)
+
+	n, err = MetadataOverhead(out)
+	fatalIfError(t, err)
+
+	exp := 0
+	cis, err := ListChunks(out)
+	fatalIfError(t, err)
+	for _, ci := range cis {
+		if ci.Type == "tEXt" || ci.Type == "iTXt" {
+			exp += ci.Length + 12
+		}
+	}
+	if n != exp || n != len(out)-len(bs)-16 {
+		t.Errorf("Expected %d bytes of metadata, got %d\n", exp, n)
+	}
+}
+
+func TestIDATSize(t *testing.T) {
+	// Noise does not compress, so the encoder splits it over several IDATs.
+	img := image.NewRGBA(image.Rect(0, 0, 128, 128))
+	_, err := rand.Read(img.Pix)
+	fatalIfError(t, err)
+	buf := &bytes.Buffer{}
+	fatalIfError(t, png.Encode(buf, img))
+	bs := buf.Bytes()
+
+	cis, err := ListChunks(bs, "IDAT")
+	fatalIfError(t, err)
+	if len(cis) < 2 {
+		t.Fatalf("Expected several IDAT chunks, got %d\n", len(cis))
+	}
+
+	exp := 0
+	for _, ci := range cis {
+		exp += ci.Length
+	}
+	n, err := IDATSize(bs)
+	fatalIfError(t, err)
+	if n != exp {
+		t.Errorf("Expected %d IDAT bytes, got %d\n", exp, n)
+	}
+
+	// Metadata does not count.
+	out, err := EmbedTEXT(bs, "Key", "Value")
+	fatalIfError(t, err)
+	if n, _ := IDATSize(out); n != exp {
+		t.Errorf("Expected %d IDAT bytes after embedding, got %d\n", exp, n)
+	}
+}
+
+func TestSuggestedMetadataBudget(t *testing.T) {
+	noise := func(side int) []byte {
+		img := image.NewRGBA(image.Rect(0, 0, side, side))
+		_, err := rand.Read(img.Pix)
+		fatalIfError(t, err)
+		buf := &bytes.Buffer{}
+		fatalIfError(t, png.Encode(buf, img))
+		return buf.Bytes()
+	}
+	small, large := noise(32), noise(128)
+
+	budget := func(data []byte, opts ...Option) (int, int) {
+		n, err := IDATSize(data)
+		fatalIfError(t, err)
+		b, err := SuggestedMetadataBudget(data, opts...)
+		fatalIfError(t, err)
+		return n, b
+	}
+
+	// Positive test cases.
+	sn, sb := budget(small)
+	ln, lb := budget(large)
+	if sb != int(float64(sn)*0.1) || lb != int(float64(ln)*0.1) || lb <= sb {
+		t.Errorf("Expected budgets of a tenth of %d and %d, got %d and %d\n", sn, ln, sb, lb)
+	}
+	if _, b := budget(large, WithMetadataBudgetRatio(0.5)); b != ln/2 {
+		t.Errorf("Expected budget %d, got %d\n", ln/2, b)
+	}
+
+	// Negative test cases.
+	if _, err := SuggestedMetadataBudget(large[:20]); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
@@ -0,0 +1,119 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// SliceElem lists the element types `WithCompactSlices` and `GetSlice`
+// support.
+type SliceElem interface {
+	int | int64 | uint | float64 | string
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// escapeSliceElem escapes the backslashes and commas of a string element.
+var escapeSliceElem = strings.NewReplacer(`\`, `\\`, `,`, `\,`)
+
+// compactSlice returns the comma separated encoding of `v`, and true, if `v`
+// is a slice of one of the `SliceElem` types.
+func compactSlice(v interface{}) ([]byte, bool) {
+	elems := []string{}
+	switch vt := v.(type) {
+	case []int:
+		for _, e := range vt {
+			elems = append(elems, strconv.Itoa(e))
+		}
+	case []int64:
+		for _, e := range vt {
+			elems = append(elems, strconv.FormatInt(e, 10))
+		}
+	case []uint:
+		for _, e := range vt {
+			elems = append(elems, strconv.FormatUint(uint64(e), 10))
+		}
+	case []float64:
+		for _, e := range vt {
+			elems = append(elems, strconv.FormatFloat(e, 'g', -1, 64))
+		}
+	case []string:
+		for _, e := range vt {
+			elems = append(elems, escapeSliceElem.Replace(e))
+		}
+	default:
+		return nil, false
+	}
+	return []byte(strings.Join(elems, ",")), true
+}
+
+// splitCompactSlice splits a value written by `compactSlice` into its
+// unescaped elements.  An empty value holds no elements.
+func splitCompactSlice(val []byte) ([]string, error) {
+	if len(val) == 0 {
+		return nil, nil
+	}
+
+	elems := []string{}
+	cur := []byte{}
+	for i := 0; i < len(val); i++ {
+		switch val[i] {
+		case '\\':
+			if i++; i == len(val) {
+				return nil, fmt.Errorf("dangling escape at offset %d", i-1)
+			}
+			cur = append(cur, val[i])
+		case ',':
+			elems = append(elems, string(cur))
+			cur = cur[:0]
+		default:
+			cur = append(cur, val[i])
+		}
+	}
+	return append(elems, string(cur)), nil
+}
+
+// GetSlice parses the value stored under `key` by `WithCompactSlices` back
+// into a slice of `T`.  An error is returned if the key is missing or an
+// element does not parse as a `T`.
+func GetSlice[T SliceElem](data []byte, key string, opts ...Option) ([]T, error) {
+	v, found, err := GetValue(data, key, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("keyword (%s) not found", key)
+	}
+
+	elems, err := splitCompactSlice(v)
+	if err != nil {
+		return nil, fmt.Errorf("keyword (%s): %w", key, err)
+	}
+
+	ret := make([]T, len(elems))
+	for i, e := range elems {
+		switch p := any(&ret[i]).(type) {
+		case *int:
+			*p, err = strconv.Atoi(e)
+		case *int64:
+			*p, err = strconv.ParseInt(e, 10, 64)
+		case *uint:
+			var u uint64
+			u, err = strconv.ParseUint(e, 10, 0)
+			*p = uint(u)
+		case *float64:
+			*p, err = strconv.ParseFloat(e, 64)
+		case *string:
+			*p = e
+		}
+		if err != nil {
+			return nil, fmt.Errorf("keyword (%s): element %d: %w", key, i, err)
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,70 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestCompactSlices(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	ints := []int{1, -2, 300}
+	strs := []string{"plain", "a,b", `back\slash`, ""}
+	out, err := EmbedPairs(bs, []KV{
+		{Key: "ints", Value: ints},
+		{Key: "strs", Value: strs},
+		{Key: "empty", Value: []int{}},
+		{Key: "floats", Value: []float64{0.5, 1e-9}},
+	}, WithCompactSlices())
+	fatalIfError(t, err)
+
+	// Positive test cases.
+	if act := textValues(t, out, "ints"); len(act) != 1 || act[0] != "1,-2,300" {
+		t.Errorf("Expected the compact encoding, got %v\n", act)
+	}
+	if act := textValues(t, out, "strs"); len(act) != 1 || act[0] != `plain,a\,b,back\\slash,` {
+		t.Errorf("Expected escaped strings, got %v\n", act)
+	}
+
+	gotInts, err := GetSlice[int](out, "ints")
+	fatalIfError(t, err)
+	if !reflect.DeepEqual(gotInts, ints) {
+		t.Errorf("Expected %v, got %v\n", ints, gotInts)
+	}
+	gotStrs, err := GetSlice[string](out, "strs")
+	fatalIfError(t, err)
+	if !reflect.DeepEqual(gotStrs, strs) {
+		t.Errorf("Expected %q, got %q\n", strs, gotStrs)
+	}
+	gotEmpty, err := GetSlice[int](out, "empty")
+	fatalIfError(t, err)
+	if gotEmpty == nil || len(gotEmpty) != 0 {
+		t.Errorf("Expected an empty slice, got %v\n", gotEmpty)
+	}
+	gotFloats, err := GetSlice[float64](out, "floats")
+	fatalIfError(t, err)
+	if !reflect.DeepEqual(gotFloats, []float64{0.5, 1e-9}) {
+		t.Errorf("Expected the floats back, got %v\n", gotFloats)
+	}
+
+	// Without the option, slices are still JSON.
+	plain, err := EmbedTEXT(bs, "ints", ints)
+	fatalIfError(t, err)
+	if act := textValues(t, plain, "ints"); len(act) != 1 || act[0] != "[1,-2,300]" {
+		t.Errorf("Expected JSON, got %v\n", act)
+	}
+
+	// Negative test cases.
+	if _, err := GetSlice[int](out, "strs"); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+	if _, err := GetSlice[int](out, "missing"); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
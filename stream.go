@@ -0,0 +1,117 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// streamErr maps an error from reading the chunk at offset `off` of a stream
+// to `ErrChunkTruncated` when the stream ended early.
+func streamErr(err error, off int64) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: chunk at offset %d", ErrChunkTruncated, off)
+	}
+	return err
+}
+
+// TransformStream copies the png read from `r` to `w`, rewriting its text
+// metadata on the way: text chunks whose keyword is in `remove` or `add` are
+// dropped, and the pairs of `add` are injected as tEXt chunks right after
+// IHDR, in sorted key order.  All other chunks are passed through as they
+// are, without buffering their data, so large images can be transformed
+// without holding them in memory.  Reading stops after the IEND chunk.
+//
+// Output is written as the input is read, so on error `w` may have received
+// a partial png.  Invalid keywords in `add` are reported before anything is
+// written.
+func TransformStream(r io.Reader, w io.Writer, add map[string]interface{}, remove []string) error {
+	block, kvs, err := newOptions(nil).pairsBlock(sortedPairs(add))
+	if err != nil {
+		return err
+	}
+	drop := map[string]bool{}
+	for _, k := range remove {
+		drop[k] = true
+	}
+	for _, kv := range kvs {
+		drop[kv.Key] = true
+	}
+
+	magic := make([]byte, len(pngMagic))
+	n, err := io.ReadFull(r, magic)
+	if err := checkSignature(magic[:n]); err != nil {
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(magic); err != nil {
+		return err
+	}
+
+	hdr := make([]byte, 8)
+	for off, i := int64(len(pngMagic)), 0; ; i++ {
+		if _, err := io.ReadFull(r, hdr); err != nil {
+			return streamErr(err, off)
+		}
+		ct := string(hdr[4:8])
+		rest := int64(binary.BigEndian.Uint32(hdr[:4]))
+		if rest > maxChunkLength {
+			return fmt.Errorf("chunk %s at offset %d declares length %d, exceeding limit %d",
+				ct, off, rest, maxChunkLength)
+		}
+		if i == 0 && ct != "IHDR" {
+			return ErrIHDRNotFirst
+		}
+		size := rest + 12
+		rest += 4 // The CRC follows the data.
+
+		// Read just enough of text chunks to find their keyword.
+		head := []byte{}
+		if isTextChunkType(ct) {
+			head = make([]byte, maxKeywordLength+1)
+			if rest-4 < int64(len(head)) {
+				head = head[:rest-4]
+			}
+			if _, err := io.ReadFull(r, head); err != nil {
+				return streamErr(err, off)
+			}
+			rest -= int64(len(head))
+
+			if pt := bytes.IndexByte(head, NULL_SEPERATOR); pt >= 0 && drop[string(head[:pt])] {
+				if _, err := io.CopyN(io.Discard, r, rest); err != nil {
+					return streamErr(err, off)
+				}
+				off += size
+				continue
+			}
+		}
+
+		if _, err := w.Write(hdr); err != nil {
+			return err
+		}
+		if _, err := w.Write(head); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(w, r, rest); err != nil {
+			return streamErr(err, off)
+		}
+		off += size
+
+		if i == 0 {
+			if _, err := w.Write(block); err != nil {
+				return err
+			}
+		}
+		if ct == "IEND" {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestTransformStream(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	in, err := EmbedTEXT(bs, "Old", "remove me")
+	fatalIfError(t, err)
+	in, err = EmbedZTXT(in, "Kept", strings.Repeat("kept ", 100))
+	fatalIfError(t, err)
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := pw.Write(in)
+		pw.CloseWithError(err)
+	}()
+
+	out := &bytes.Buffer{}
+	fatalIfError(t, TransformStream(pr, out, map[string]interface{}{"Via": "proxy"}, []string{"Old"}))
+
+	// Positive test cases.
+	m, err := extractRecords(out.Bytes())
+	fatalIfError(t, err)
+	if len(m) != 2 || string(m["Via"]) != "proxy" || len(m["Kept"]) != 500 {
+		t.Errorf("Expected Via and Kept, got %v\n", m)
+	}
+
+	removed, err := RemoveTEXT(in, "Old")
+	fatalIfError(t, err)
+	exp, err := EmbedTEXT(removed, "Via", "proxy")
+	fatalIfError(t, err)
+	if !bytes.Equal(out.Bytes(), exp) {
+		t.Errorf("Expected the same bytes as embedding in memory\n")
+	}
+
+	// Negative test cases.
+	for _, tc := range []struct {
+		data []byte
+		err  error
+	}{
+		{data: in[:4], err: ErrNotPNG},
+		{data: in[:len(in)-6], err: ErrChunkTruncated},
+		{data: withRawChunkFirst(t, in), err: ErrIHDRNotFirst},
+	} {
+		err := TransformStream(bytes.NewReader(tc.data), ioutil.Discard, nil, nil)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("Expected %v, got %v\n", tc.err, err)
+		}
+	}
+	if err := TransformStream(bytes.NewReader(in), ioutil.Discard, map[string]interface{}{"": 1}, nil); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
@@ -0,0 +1,112 @@
+// Package testutil provides helpers for testing code which embeds metadata
+// into png images.
+package testutil
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"strings"
+	"testing"
+
+	pngembed "github.com/deniz-dilaverler/png-embed"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// UpdateEnv names the environment variable which, when set to a non-empty
+// value, makes `AssertGolden` rewrite golden files rather than compare them.
+const UpdateEnv = "PNGEMBED_UPDATE_GOLDEN"
+
+// updating returns true if golden files should be rewritten: when the test
+// binary defines a boolean `-update` flag of its own and it is set, or when
+// `UpdateEnv` is set.  No flag is registered here, so importing this package
+// never clashes with a caller's `-update` flag.
+func updating() bool {
+	if f := flag.Lookup("update"); f != nil {
+		if g, ok := f.Value.(flag.Getter); ok {
+			if b, ok := g.Get().(bool); ok && b {
+				return true
+			}
+		}
+	}
+	return os.Getenv(UpdateEnv) != ""
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// AssertGolden fails `t` unless `data` is byte for byte equal to the contents
+// of the golden file at `goldenPath`.  When the test binary is run with its
+// own `-update` flag set, or with `PNGEMBED_UPDATE_GOLDEN=1` in the
+// environment, the golden file is rewritten with `data` instead.  On mismatch,
+// the chunk structure of both files is listed side by side.
+func AssertGolden(t testing.TB, data []byte, goldenPath string) {
+	t.Helper()
+
+	if updating() {
+		if err := os.WriteFile(goldenPath, data, 0644); err != nil {
+			t.Fatalf("failed to update golden file: %s", err)
+		}
+		return
+	}
+
+	golden, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("failed to read golden file (set "+UpdateEnv+"=1 to create it): %s", err)
+		return
+	}
+	if !bytes.Equal(data, golden) {
+		t.Errorf("output does not match golden file %s:\n%s", goldenPath, Diff(golden, data))
+	}
+}
+
+// Diff describes how the png data `got` differs from `want`: the offset of the
+// first differing byte, and the chunks of both listed side by side, with
+// differing rows marked by `!`.  Chunks are described by type, data length and
+// a checksum of their data.
+func Diff(want, got []byte) string {
+	n := 0
+	for n < len(want) && n < len(got) && want[n] == got[n] {
+		n++
+	}
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "first difference at byte %d (want %d bytes, got %d)\n", n, len(want), len(got))
+
+	ws, gs := describeChunks(want), describeChunks(got)
+	fmt.Fprintf(sb, "  %-32s %s\n", "want", "got")
+	for i := 0; i < len(ws) || i < len(gs); i++ {
+		w, g := "", ""
+		if i < len(ws) {
+			w = ws[i]
+		}
+		if i < len(gs) {
+			g = gs[i]
+		}
+		mark := " "
+		if w != g {
+			mark = "!"
+		}
+		fmt.Fprintf(sb, "%s %-32s %s\n", mark, w, g)
+	}
+	return sb.String()
+}
+
+// describeChunks returns a one line description of each chunk in `data`.
+func describeChunks(data []byte) []string {
+	cis, err := pngembed.ListChunks(data)
+	if err != nil {
+		return []string{"<" + err.Error() + ">"}
+	}
+
+	ret := make([]string, 0, len(cis))
+	for _, ci := range cis {
+		d := data[ci.Offset+8 : ci.Offset+8+ci.Length]
+		ret = append(ret, fmt.Sprintf("%s len=%d sum=%08x", ci.Type, ci.Length, crc32.ChecksumIEEE(d)))
+	}
+	return ret
+}
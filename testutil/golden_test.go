@@ -0,0 +1,100 @@
+package testutil
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pngembed "github.com/deniz-dilaverler/png-embed"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+const redPng = "../fixtures/red.png"
+
+// recorder is a `testing.TB` which records failures rather than reporting
+// them.
+type recorder struct {
+	testing.TB
+	failed bool
+	msg    string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failed = true
+	r.msg = fmt.Sprintf(format, args...)
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestAssertGolden(t *testing.T) {
+	bs, err := os.ReadFile(redPng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := pngembed.EmbedTEXT(bs, "Key", "Value")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join(t.TempDir(), "out.golden")
+	if err := os.WriteFile(golden, out, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Positive test cases.
+	r := &recorder{TB: t}
+	AssertGolden(r, out, golden)
+	if r.failed {
+		t.Errorf("Expected match, got failure: %s\n", r.msg)
+	}
+
+	// Negative test cases.
+	other, err := pngembed.EmbedTEXT(bs, "Key", "Other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = &recorder{TB: t}
+	AssertGolden(r, other, golden)
+	if !r.failed {
+		t.Errorf("Expected mismatch to fail\n")
+	}
+	if !strings.Contains(r.msg, "! tEXt len=9") || strings.Contains(r.msg, "! IHDR") {
+		t.Errorf("Expected the tEXt row alone to be marked, got:\n%s\n", r.msg)
+	}
+
+	r = &recorder{TB: t}
+	AssertGolden(r, out, filepath.Join(t.TempDir(), "missing.golden"))
+	if !r.failed {
+		t.Errorf("Expected missing golden file to fail\n")
+	}
+}
+
+func TestAssertGoldenUpdate(t *testing.T) {
+	// Importing the package must leave the `-update` flag to callers.
+	if flag.Lookup("update") != nil {
+		t.Fatalf("Expected no -update flag to be registered\n")
+	}
+
+	golden := filepath.Join(t.TempDir(), "new.golden")
+	t.Setenv(UpdateEnv, "1")
+	r := &recorder{TB: t}
+	AssertGolden(r, []byte("data"), golden)
+	if r.failed {
+		t.Errorf("Expected update to succeed, got failure: %s\n", r.msg)
+	}
+	if bs, err := os.ReadFile(golden); err != nil || string(bs) != "data" {
+		t.Errorf("Expected the golden file written, got %q %v\n", bs, err)
+	}
+}
@@ -0,0 +1,82 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Keywords of the chunks `EmbedThumbnail` writes: the zTXt chunk holding the
+// standard base64 encoding of the thumbnail, and the tEXt chunks holding its
+// width and height in pixels as decimal integers.
+const (
+	ThumbnailKey       = "pngembed:thumbnail"
+	ThumbnailWidthKey  = ThumbnailKey + ":width"
+	ThumbnailHeightKey = ThumbnailKey + ":height"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// EmbedThumbnail stores the preview image `thumb`, in any format, along with
+// its width `w` and height `h`, so catalogs can show a preview without
+// decoding the full image.  The thumbnail is base64-encoded and compressed
+// into a zTXt chunk keyed `pngembed:thumbnail`; the dimensions go into tEXt
+// chunks keyed `pngembed:thumbnail:width` and `pngembed:thumbnail:height`.
+// Any thumbnail already present is replaced.
+func EmbedThumbnail(data []byte, thumb []byte, w, h int) ([]byte, error) {
+	if w <= 0 || h <= 0 {
+		return nil, fmt.Errorf("invalid thumbnail dimensions %dx%d", w, h)
+	}
+
+	b64 := []byte(base64.StdEncoding.EncodeToString(thumb))
+	block, _ := buildChunk(`zTXt`, formatZTXTChunk(deflate(b64), ThumbnailKey))
+	for _, kv := range [][2]string{
+		{ThumbnailWidthKey, strconv.Itoa(w)},
+		{ThumbnailHeightKey, strconv.Itoa(h)},
+	} {
+		pngChunk, _ := buildChunk(`tEXt`, formatTEXTChunk([]byte(kv[1]), kv[0]))
+		block = append(block, pngChunk...)
+	}
+
+	data, err := stripText(data, func(ct, ck string) bool {
+		return ck == ThumbnailKey || ck == ThumbnailWidthKey || ck == ThumbnailHeightKey
+	})
+	if err != nil {
+		return nil, err
+	}
+	return embed(data, block)
+}
+
+// ExtractThumbnail returns the thumbnail bytes and dimensions stored by
+// `EmbedThumbnail`.  An error is returned if the PNG data has no thumbnail,
+// or one whose chunks are malformed.
+func ExtractThumbnail(data []byte) (thumb []byte, w, h int, err error) {
+	m, err := extractRecords(data)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	b64, ok := m[ThumbnailKey]
+	if !ok {
+		return nil, 0, 0, fmt.Errorf("keyword (%s) not found", ThumbnailKey)
+	}
+	if thumb, err = base64.StdEncoding.DecodeString(string(b64)); err != nil {
+		return nil, 0, 0, fmt.Errorf("keyword (%s): %w", ThumbnailKey, err)
+	}
+
+	dims := [2]int{}
+	for i, k := range []string{ThumbnailWidthKey, ThumbnailHeightKey} {
+		v, ok := m[k]
+		if !ok {
+			return nil, 0, 0, fmt.Errorf("keyword (%s) not found", k)
+		}
+		if dims[i], err = strconv.Atoi(string(v)); err != nil {
+			return nil, 0, 0, fmt.Errorf("keyword (%s): %w", k, err)
+		}
+	}
+	return thumb, dims[0], dims[1], nil
+}
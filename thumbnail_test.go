@@ -0,0 +1,65 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/ioutil"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestEmbedThumbnail(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	img := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	img.Set(1, 1, color.RGBA{R: 0xff, A: 0xff})
+	buf := &bytes.Buffer{}
+	fatalIfError(t, jpeg.Encode(buf, img, nil))
+	thumb := buf.Bytes()
+
+	out, err := EmbedThumbnail(bs, thumb, 4, 3)
+	fatalIfError(t, err)
+
+	// Positive test cases.
+	_, _, ztxt, err := ExtractByType(out)
+	fatalIfError(t, err)
+	if _, ok := ztxt[ThumbnailKey]; !ok {
+		t.Errorf("Expected the thumbnail in a zTXt chunk\n")
+	}
+
+	got, w, h, err := ExtractThumbnail(out)
+	fatalIfError(t, err)
+	if !bytes.Equal(got, thumb) || w != 4 || h != 3 {
+		t.Errorf("Expected the 4x3 thumbnail back, got %d bytes at %dx%d\n", len(got), w, h)
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(got)); err != nil {
+		t.Errorf("Expected a decodable JPEG, got %v\n", err)
+	}
+
+	// Replacing the thumbnail leaves a single set of chunks.
+	out, err = EmbedThumbnail(out, []byte("tiny"), 1, 1)
+	fatalIfError(t, err)
+	if n := len(textKeywords(t, out)); n != 3 {
+		t.Errorf("Expected 3 thumbnail chunks, got %d\n", n)
+	}
+	if got, w, h, _ := ExtractThumbnail(out); string(got) != "tiny" || w != 1 || h != 1 {
+		t.Errorf("Expected the replaced thumbnail, got %q at %dx%d\n", got, w, h)
+	}
+
+	// Negative test cases.
+	if _, err := EmbedThumbnail(bs, thumb, 0, 3); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+	if _, _, _, err := ExtractThumbnail(bs); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
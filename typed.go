@@ -0,0 +1,167 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// TypeHintSuffix is appended to a keyword to form the keyword of the
+// companion chunk written by `WithTypeHints`.
+const TypeHintSuffix = ":__type"
+
+////////////////////////////////////////////////////////////////////////////////
+
+// typeHint returns the type name recorded for `v` by `WithTypeHints`.  Only
+// structs, and pointers to them, are hinted.
+func typeHint(v interface{}) (string, bool) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return "", false
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return "", false
+	}
+	return t.String(), true
+}
+
+// ExtractTyped returns the value stored under `k`, using the type hint
+// written by `WithTypeHints` to pick a decoding strategy:
+//
+//   - If the hint names the type of one of the sample values in `types`, the
+//     JSON value is decoded into a new value of that type.
+//   - If the hint names any other type, the JSON value is decoded generically
+//     into maps, slices and scalars.
+//   - Without a hint, the raw value is returned as a string.
+func ExtractTyped(data []byte, k string, types ...interface{}) (interface{}, error) {
+	m, err := ExtractAll(data)
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := m[k]
+	if !ok {
+		return nil, fmt.Errorf("keyword (%s) not found", k)
+	}
+	hint, ok := m[k+TypeHintSuffix]
+	if !ok {
+		return string(v), nil
+	}
+
+	for _, sample := range types {
+		t := reflect.TypeOf(sample)
+		if t == nil || t.String() != string(hint) {
+			continue
+		}
+		pv := reflect.New(t)
+		if err := json.Unmarshal(v, pv.Interface()); err != nil {
+			return nil, err
+		}
+		return pv.Elem().Interface(), nil
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(v, &generic); err != nil {
+		return nil, err
+	}
+	return generic, nil
+}
+
+// ExtractInto decodes the value stored under `key` into a `T`.  Strings are
+// stored as is rather than as JSON, so a string `T` receives the raw text;
+// every other type is decoded from JSON, or with the function set by
+// `WithUnmarshal`.  An error is returned if the key is missing or fails to
+// decode.
+func ExtractInto[T any](data []byte, key string, opts ...Option) (T, error) {
+	var ret T
+	v, found, err := GetValue(data, key, opts...)
+	if err != nil {
+		return ret, err
+	}
+	if !found {
+		return ret, fmt.Errorf("keyword (%s) not found", key)
+	}
+
+	if s, ok := any(&ret).(*string); ok {
+		*s = string(v)
+		return ret, nil
+	}
+	if err := newOptions(opts).decode(v, &ret); err != nil {
+		return ret, fmt.Errorf("keyword (%s): %w", key, err)
+	}
+	return ret, nil
+}
+
+// GetOr is like `ExtractInto` but returns `def` instead of an error when the
+// key is missing or fails to decode.  Use it for config-like metadata with a
+// sensible default.
+func GetOr[T any](data []byte, key string, def T, opts ...Option) T {
+	v, err := ExtractInto[T](data, key, opts...)
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// ExtractFlattened decodes the JSON value stored under `key` and flattens it
+// into a map from dotted paths to leaf values, so nested values can be
+// queried without knowing the value's shape: a struct embedded with field
+// `struct_val` holding `inner_int` yields the path `struct_val.inner_int`.
+// Array elements are addressed by index, as in `items.0`.  Empty objects and
+// arrays are kept as leaves.  A scalar value is returned under the empty path.
+func ExtractFlattened(data []byte, key string, opts ...Option) (map[string]interface{}, error) {
+	v, found, err := GetValue(data, key, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("keyword (%s) not found", key)
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(v, &generic); err != nil {
+		return nil, fmt.Errorf("keyword (%s): %w", key, err)
+	}
+
+	ret := map[string]interface{}{}
+	flatten(ret, "", generic)
+	return ret, nil
+}
+
+// flatten stores the leaves of the decoded JSON value `v` in `ret`, under
+// `path` joined by dots with their keys and indices.
+func flatten(ret map[string]interface{}, path string, v interface{}) {
+	join := func(k string) string {
+		if path == "" {
+			return k
+		}
+		return path + "." + k
+	}
+
+	switch vt := v.(type) {
+	case map[string]interface{}:
+		if len(vt) == 0 {
+			ret[path] = vt
+		}
+		for k, e := range vt {
+			flatten(ret, join(k), e)
+		}
+	case []interface{}:
+		if len(vt) == 0 {
+			ret[path] = vt
+		}
+		for i, e := range vt {
+			flatten(ret, join(strconv.Itoa(i)), e)
+		}
+	default:
+		ret[path] = v
+	}
+}
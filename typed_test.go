@@ -0,0 +1,153 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+type hintedPoint struct {
+	X, Y int
+}
+
+func TestTypeHints(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	// Off by default.
+	out, err := EmbedTEXT(bs, "point", hintedPoint{X: 1, Y: 2})
+	fatalIfError(t, err)
+	if act := textValues(t, out, "point:__type"); len(act) != 0 {
+		t.Errorf("Expected no type hint, got %v\n", act)
+	}
+
+	out, err = EmbedTEXT(bs, "point", hintedPoint{X: 1, Y: 2}, WithTypeHints())
+	fatalIfError(t, err)
+	out, err = EmbedTEXT(out, "name", "gopher", WithTypeHints())
+	fatalIfError(t, err)
+
+	act := textValues(t, out, "point:__type")
+	if len(act) != 1 || act[0] != "pngembed.hintedPoint" {
+		t.Errorf("Expected pngembed.hintedPoint, got %v\n", act)
+	}
+	if act := textValues(t, out, "name:__type"); len(act) != 0 {
+		t.Errorf("Expected no type hint for a string, got %v\n", act)
+	}
+
+	v, err := ExtractTyped(out, "point", hintedPoint{})
+	fatalIfError(t, err)
+	if p, ok := v.(hintedPoint); !ok || p.X != 1 || p.Y != 2 {
+		t.Errorf("Expected hintedPoint{1, 2}, got %#v\n", v)
+	}
+
+	v, err = ExtractTyped(out, "point")
+	fatalIfError(t, err)
+	if m, ok := v.(map[string]interface{}); !ok || m["X"] != 1.0 {
+		t.Errorf("Expected generic map, got %#v\n", v)
+	}
+
+	v, err = ExtractTyped(out, "name")
+	fatalIfError(t, err)
+	if v != "gopher" {
+		t.Errorf("Expected gopher, got %#v\n", v)
+	}
+}
+
+func TestExtractIntoAndGetOr(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedMulti(bs, map[string]interface{}{
+		"retries": 3,
+		"name":    "demo",
+		"point":   hintedPoint{X: 1, Y: 2},
+		"broken":  "{",
+	})
+	fatalIfError(t, err)
+
+	if got := GetOr(out, "retries", 5); got != 3 {
+		t.Errorf("Expected 3, got %d\n", got)
+	}
+	if got := GetOr(out, "missing", 5); got != 5 {
+		t.Errorf("Expected default 5, got %d\n", got)
+	}
+	if got := GetOr(out, "broken", hintedPoint{X: 9}); got.X != 9 {
+		t.Errorf("Expected default for undecodable value, got %+v\n", got)
+	}
+	if got := GetOr(out, "name", "default"); got != "demo" {
+		t.Errorf("Expected demo, got %s\n", got)
+	}
+
+	p, err := ExtractInto[hintedPoint](out, "point")
+	fatalIfError(t, err)
+	if p.X != 1 || p.Y != 2 {
+		t.Errorf("Unexpected point %+v\n", p)
+	}
+
+	// Negative test cases.
+	if _, err := ExtractInto[int](out, "missing"); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+	if _, err := ExtractInto[int](out, "name"); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
+
+func TestExtractFlattened(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	// The shape of `SampleStruct` from the example application.
+	type InnerStruct struct {
+		InnerBool bool   `json:"inner_bool"`
+		InnerStr  string `json:"inner_str"`
+		InnerInt  int    `json:"inner_int"`
+	}
+	type SampleStruct struct {
+		StrVal    string      `json:"str_val"`
+		IntVal    int         `json:"int_val"`
+		BoolVal   bool        `json:"bool_val"`
+		StructVal InnerStruct `json:"struct_val"`
+		List      []string    `json:"list"`
+	}
+
+	out, err := EmbedTEXT(bs, "Sample", SampleStruct{
+		StrVal:    "hello",
+		IntVal:    42,
+		BoolVal:   true,
+		StructVal: InnerStruct{InnerStr: "world", InnerInt: 7},
+		List:      []string{"a", "b"},
+	})
+	fatalIfError(t, err)
+
+	m, err := ExtractFlattened(out, "Sample")
+	fatalIfError(t, err)
+	for path, exp := range map[string]interface{}{
+		"str_val":               "hello",
+		"int_val":               42.0,
+		"struct_val.inner_str":  "world",
+		"struct_val.inner_int":  7.0,
+		"struct_val.inner_bool": false,
+		"list.1":                "b",
+	} {
+		if m[path] != exp {
+			t.Errorf("Expected %v at %s, got %v\n", exp, path, m[path])
+		}
+	}
+	if len(m) != 8 {
+		t.Errorf("Expected 8 leaves, got %v\n", m)
+	}
+
+	// Negative test cases.
+	if _, err := ExtractFlattened(out, "Missing"); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+	out, err = EmbedTEXT(out, "Plain", "not json")
+	fatalIfError(t, err)
+	if _, err := ExtractFlattened(out, "Plain"); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
@@ -0,0 +1,140 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"fmt"
+
+	"github.com/sabhiram/pngr"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// UpdateTEXT replaces the value of the existing `tEXt` chunk with keyword `k`
+// in place, leaving all other chunks untouched.  When the keyword appears
+// more than once, the chunk(s) updated are selected by `WithDuplicateStrategy`
+// and default to the first one.  An error is returned if no `tEXt` chunk with
+// the keyword exists.
+func UpdateTEXT(data []byte, k string, v interface{}, opts ...Option) ([]byte, error) {
+	if err := ValidateKeyword(k); err != nil {
+		return nil, err
+	}
+
+	o := newOptions(opts)
+	data = o.stripBOM(data)
+
+	val, err := o.serializeTEXT(v)
+	if err != nil {
+		return nil, err
+	}
+	pngChunk, err := buildChunk(`tEXt`, formatTEXTChunk(val, k))
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, err := scanChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []int{}
+	for i, c := range chunks {
+		if c.ct != "tEXt" {
+			continue
+		}
+		ck, _, err := parseTEXT(c.data(data))
+		if err == nil && ck == k {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("keyword (%s) not found", k)
+	}
+
+	switch o.duplicates {
+	case DuplicateFirst:
+		matches = matches[:1]
+	case DuplicateLast:
+		matches = matches[len(matches)-1:]
+	}
+
+	next := 0
+	return rewriteChunks(data, chunks, func(i int, c chunk) []byte {
+		if next < len(matches) && matches[next] == i {
+			next++
+			return pngChunk
+		}
+		return c.raw(data)
+	}), nil
+}
+
+// EmbedTEXTIfAbsent is like `EmbedTEXT` but only embeds the value when no text
+// chunk of any type has keyword `k` yet, so existing metadata is never
+// clobbered.  The returned flag reports whether the value was written; if not,
+// `data` is returned unchanged.
+func EmbedTEXTIfAbsent(data []byte, k string, v interface{}, opts ...Option) ([]byte, bool, error) {
+	found, err := HasKey(data, k, opts...)
+	if err != nil {
+		return nil, false, err
+	}
+	if found {
+		return data, false, nil
+	}
+
+	out, err := EmbedTEXT(data, k, v, opts...)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// RemoveTEXT removes every `tEXt` chunk with keyword `k` from the PNG data.
+// All other chunks, including private and unknown types, are copied through
+// byte for byte.  Removing an absent keyword is not an error.
+func RemoveTEXT(data []byte, k string) ([]byte, error) {
+	return stripText(data, func(ct, ck string) bool {
+		return ct == "tEXt" && ck == k
+	})
+}
+
+// RenameKey renames the keyword of every text chunk keyed `oldKey` to `newKey`.
+// Only the keyword is rewritten: the value bytes, any compression and the
+// chunk type are preserved.  An error is returned if `newKey` is not a valid
+// keyword, if `oldKey` is absent, or if `newKey` is already present.  A chunk
+// to rename whose CRC does not match is an error, rather than being given a
+// fresh CRC which would hide the corruption.
+func RenameKey(data []byte, oldKey, newKey string) ([]byte, error) {
+	if err := ValidateKeyword(newKey); err != nil {
+		return nil, err
+	}
+
+	chunks, err := scanChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	found := false
+	for _, c := range chunks {
+		switch k, _ := c.keyword(data); k {
+		case oldKey:
+			if !c.crcValid(data) {
+				return nil, pngr.ErrBadCRC
+			}
+			found = true
+		case newKey:
+			return nil, fmt.Errorf("keyword (%s) already exists", newKey)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("keyword (%s) not found", oldKey)
+	}
+
+	return rewriteChunks(data, chunks, func(i int, c chunk) []byte {
+		if k, ok := c.keyword(data); !ok || k != oldKey {
+			return c.raw(data)
+		}
+		d := c.data(data)
+		pngChunk, _ := buildChunk(c.ct, append([]byte(newKey), d[len(oldKey):]...))
+		return pngChunk
+	}), nil
+}
@@ -0,0 +1,186 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/sabhiram/pngr"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// textValues returns the values of all `tEXt` chunks with keyword `k` in file
+// order.
+func textValues(t *testing.T, data []byte, k string) []string {
+	chunks, err := scanChunks(data)
+	fatalIfError(t, err)
+
+	vs := []string{}
+	for _, c := range chunks {
+		if c.ct != "tEXt" {
+			continue
+		}
+		ck, v, err := parseTEXT(c.data(data))
+		fatalIfError(t, err)
+		if ck == k {
+			vs = append(vs, string(v))
+		}
+	}
+	return vs
+}
+
+func TestUpdateTEXT(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	// Chunks are injected after IHDR, so "B" precedes "A" in the file.
+	dup, err := EmbedTEXT(bs, "Dup", "A")
+	fatalIfError(t, err)
+	dup, err = EmbedTEXT(dup, "Dup", "B")
+	fatalIfError(t, err)
+
+	for _, tc := range []struct {
+		opts []Option
+		exp  []string
+	}{
+		{opts: nil, exp: []string{"New", "A"}},
+		{opts: []Option{WithDuplicateStrategy(DuplicateFirst)}, exp: []string{"New", "A"}},
+		{opts: []Option{WithDuplicateStrategy(DuplicateLast)}, exp: []string{"B", "New"}},
+		{opts: []Option{WithDuplicateStrategy(DuplicateAll)}, exp: []string{"New", "New"}},
+	} {
+		out, err := UpdateTEXT(dup, "Dup", "New", tc.opts...)
+		fatalIfError(t, err)
+
+		act := textValues(t, out, "Dup")
+		if len(act) != len(tc.exp) || act[0] != tc.exp[0] || act[1] != tc.exp[1] {
+			t.Errorf("Expected %v, got %v\n", tc.exp, act)
+		}
+	}
+
+	if _, err := UpdateTEXT(bs, "Missing", "New"); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}
+
+// withRawChunk returns a copy of `data` with a chunk of any type, known or
+// not, inserted right after IHDR.
+func withRawChunk(t *testing.T, data []byte, ct string, cdata []byte) []byte {
+	chunks, err := scanChunks(data)
+	fatalIfError(t, err)
+
+	raw := make([]byte, 4, 12+len(cdata))
+	binary.BigEndian.PutUint32(raw, uint32(len(cdata)))
+	raw = append(raw, ct...)
+	raw = append(raw, cdata...)
+	raw = binary.BigEndian.AppendUint32(raw, crc32.ChecksumIEEE(raw[4:]))
+
+	off := chunks[0].end()
+	out := append([]byte{}, data[:off]...)
+	out = append(out, raw...)
+	return append(out, data[off:]...)
+}
+
+func TestRemoveTEXTPreservesUnknownChunks(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	vpag := []byte{0, 0, 0, 16, 0, 0, 0, 16, 0}
+	in := withRawChunk(t, bs, "vpAg", vpag)
+	in, err = EmbedTEXT(in, "Keep", "Value")
+	fatalIfError(t, err)
+	in, err = EmbedTEXT(in, "Drop", "Value")
+	fatalIfError(t, err)
+
+	out, err := RemoveTEXT(in, "Drop")
+	fatalIfError(t, err)
+
+	exp, err := EmbedTEXT(withRawChunk(t, bs, "vpAg", vpag), "Keep", "Value")
+	fatalIfError(t, err)
+	if !bytes.Equal(out, exp) {
+		t.Errorf("Expected only the `Drop` chunk to be removed\n")
+	}
+
+	cts := chunkTypes(t, out)
+	if indexOf(cts, "vpAg") < 0 {
+		t.Errorf("Expected vpAg chunk to survive, got %v\n", cts)
+	}
+}
+
+func TestRenameKey(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	val := strings.Repeat("build ", 100)
+	out, err := EmbedZTXT(bs, "build_id", val)
+	fatalIfError(t, err)
+	out, err = EmbedTEXT(out, "Other", "x")
+	fatalIfError(t, err)
+
+	// Negative test cases.
+	for _, tc := range []struct{ oldKey, newKey string }{
+		{oldKey: "missing", newKey: "build-id"},
+		{oldKey: "build_id", newKey: "Other"},
+		{oldKey: "build_id", newKey: " bad"},
+	} {
+		if _, err := RenameKey(out, tc.oldKey, tc.newKey); err == nil {
+			t.Errorf("Expected error renaming %s to %s, got nil!\n", tc.oldKey, tc.newKey)
+		}
+	}
+	bad := append([]byte{}, out...)
+	chunks, err := scanChunks(bad)
+	fatalIfError(t, err)
+	bad[chunks[indexOfChunk(chunks, "zTXt")].end()-1] ^= 0xff
+	if _, err := RenameKey(bad, "build_id", "build-id"); !errors.Is(err, pngr.ErrBadCRC) {
+		t.Errorf("Expected pngr.ErrBadCRC, got %v\n", err)
+	}
+
+	// Positive test cases.
+	renamed, err := RenameKey(out, "build_id", "build-id")
+	fatalIfError(t, err)
+
+	v, ct, found, err := GetValueWithType(renamed, "build-id")
+	fatalIfError(t, err)
+	if !found || ct != "zTXt" || string(v) != val {
+		t.Errorf("Expected zTXt value to survive rename, got %s %q\n", ct, v)
+	}
+	if _, found, _ := GetValue(renamed, "build_id"); found {
+		t.Errorf("Expected old keyword to be gone\n")
+	}
+	if len(renamed) != len(out) {
+		t.Errorf("Expected %d bytes, got %d\n", len(out), len(renamed))
+	}
+}
+
+func TestEmbedTEXTIfAbsent(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, wrote, err := EmbedTEXTIfAbsent(bs, "Author", "default")
+	fatalIfError(t, err)
+	if !wrote {
+		t.Errorf("Expected the first call to write\n")
+	}
+
+	again, wrote, err := EmbedTEXTIfAbsent(out, "Author", "other")
+	fatalIfError(t, err)
+	if wrote || !bytes.Equal(again, out) {
+		t.Errorf("Expected the second call to be a no-op\n")
+	}
+	if vs := textValues(t, again, "Author"); len(vs) != 1 || vs[0] != "default" {
+		t.Errorf("Expected [default], got %v\n", vs)
+	}
+
+	// Keys held in other text chunk types count as present too.
+	out, err = EmbedITXT(bs, "Author", "intl")
+	fatalIfError(t, err)
+	if _, wrote, _ := EmbedTEXTIfAbsent(out, "Author", "default"); wrote {
+		t.Errorf("Expected an iTXt keyword to block the write\n")
+	}
+}
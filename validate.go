@@ -0,0 +1,44 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"fmt"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// ValidatePNG checks the overall structure of the PNG data: the magic number,
+// an IHDR chunk first with a valid CRC, no chunk cut short, and a closing IEND
+// chunk.  Errors wrap `ErrNotPNG`, `ErrIHDRNotFirst`, `ErrCRCMismatch` or
+// `ErrChunkTruncated` where they apply.  The CRCs of other chunks are not
+// checked; see `VerifyAllCRCs`.
+func ValidatePNG(data []byte) error {
+	chunks, err := scanChunks(data)
+	if err != nil {
+		return err
+	}
+	if len(chunks) == 0 || chunks[0].ct != "IHDR" {
+		return ErrIHDRNotFirst
+	}
+	if !chunks[0].crcValid(data) {
+		return fmt.Errorf("%w: IHDR chunk", ErrCRCMismatch)
+	}
+	if chunks[len(chunks)-1].ct != "IEND" {
+		return errors.New("png is missing its IEND chunk")
+	}
+	return nil
+}
+
+// ExtractAllValidated is like `ExtractAll` but first checks the PNG data with
+// `ValidatePNG`, returning its error rather than any metadata for broken
+// files.  It is the safest way to read metadata from untrusted input.
+func ExtractAllValidated(data []byte, opts ...Option) (map[string][]byte, error) {
+	o := newOptions(opts)
+	data = o.stripBOM(data)
+	if err := ValidatePNG(data); err != nil {
+		return nil, err
+	}
+	return ExtractAll(data, opts...)
+}
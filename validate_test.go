@@ -0,0 +1,69 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+func TestExtractAllValidated(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	out, err := EmbedTEXT(bs, "Key", "Value")
+	fatalIfError(t, err)
+	chunks, err := scanChunks(out)
+	fatalIfError(t, err)
+	iend := chunks[len(chunks)-1]
+
+	// Positive test cases.
+	m, err := ExtractAllValidated(out)
+	fatalIfError(t, err)
+	if len(m) != 1 || string(m["Key"]) != "Value" {
+		t.Errorf("Unexpected records %v\n", m)
+	}
+
+	// Negative test cases.
+	badIHDR := append([]byte{}, out...)
+	badIHDR[chunks[0].end()-1] ^= 0xff
+	noIEND := out[:iend.offset]
+	truncated := out[:iend.offset-1]
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{name: "not png", data: out[1:], err: ErrNotPNG},
+		{name: "bad IHDR CRC", data: badIHDR, err: ErrCRCMismatch},
+		{name: "IHDR not first", data: withRawChunkFirst(t, out), err: ErrIHDRNotFirst},
+		{name: "missing IEND", data: noIEND},
+		{name: "truncated", data: truncated, err: ErrChunkTruncated},
+	} {
+		m, err := ExtractAllValidated(tc.data)
+		if err == nil || (tc.err != nil && !errors.Is(err, tc.err)) {
+			t.Errorf("%s: expected error %v, got %v\n", tc.name, tc.err, err)
+		}
+		if m != nil {
+			t.Errorf("%s: expected no records, got %v\n", tc.name, m)
+		}
+	}
+
+	// ExtractAll, in contrast, reads what it can of a partial file.
+	if m, _ := ExtractAll(noIEND); len(m) != 1 {
+		t.Errorf("Expected ExtractAll to read the partial file\n")
+	}
+}
+
+// withRawChunkFirst returns `data` with a gAMA chunk moved in front of IHDR.
+func withRawChunkFirst(t *testing.T, data []byte) []byte {
+	gama, err := buildChunk("gAMA", []byte{0, 0, 0xb1, 0x8f})
+	fatalIfError(t, err)
+	out := append([]byte{}, data[:len(pngMagic)]...)
+	out = append(out, gama...)
+	return append(out, data[len(pngMagic):]...)
+}
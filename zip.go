@@ -0,0 +1,75 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// EmbedInZip embeds `v` under `k`, as `EmbedTEXT` does, into every entry of
+// the ZIP archive `zipData` whose name ends in ".png", in any case, and
+// returns the new archive.  All other entries are copied through unchanged,
+// without recompressing them.  An error naming the entry is returned if any
+// png entry cannot be embedded into.
+func EmbedInZip(zipData []byte, k string, v interface{}, opts ...Option) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	if err := zw.SetComment(zr.Comment); err != nil {
+		return nil, err
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !strings.EqualFold(path.Ext(f.Name), ".png") {
+			if err := zw.Copy(f); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err := embedInZipEntry(zw, f, k, v, opts); err != nil {
+			return nil, fmt.Errorf("zip entry (%s): %w", f.Name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// embedInZipEntry writes the png entry `f` to `zw` with `v` embedded under
+// `k`, keeping the entry's header fields.
+func embedInZipEntry(zw *zip.Writer, f *zip.File, k string, v interface{}, opts []Option) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return err
+	}
+
+	out, err := EmbedTEXT(data, k, v, opts...)
+	if err != nil {
+		return err
+	}
+
+	fh := f.FileHeader
+	w, err := zw.CreateHeader(&fh)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
@@ -0,0 +1,83 @@
+package pngembed
+
+////////////////////////////////////////////////////////////////////////////////
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+
+// zipEntries returns the contents of the entries of the ZIP archive `data`,
+// keyed by name.
+func zipEntries(t *testing.T, data []byte) map[string][]byte {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	fatalIfError(t, err)
+
+	ret := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		fatalIfError(t, err)
+		ret[f.Name], err = io.ReadAll(rc)
+		fatalIfError(t, err)
+		rc.Close()
+	}
+	return ret
+}
+
+func TestEmbedInZip(t *testing.T) {
+	bs, err := ioutil.ReadFile(redPng)
+	fatalIfError(t, err)
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, data := range map[string][]byte{
+		"a.png":         bs,
+		"icons/B.PNG":   bs,
+		"readme.txt":    []byte("not an image"),
+		"fake.png.orig": bs,
+	} {
+		w, err := zw.Create(name)
+		fatalIfError(t, err)
+		_, err = w.Write(data)
+		fatalIfError(t, err)
+	}
+	fatalIfError(t, zw.Close())
+
+	out, err := EmbedInZip(buf.Bytes(), "Bundle", "v1")
+	fatalIfError(t, err)
+
+	// Positive test cases.
+	entries := zipEntries(t, out)
+	if len(entries) != 4 {
+		t.Fatalf("Expected 4 entries, got %d\n", len(entries))
+	}
+	for _, name := range []string{"a.png", "icons/B.PNG"} {
+		if v, found, err := GetValue(entries[name], "Bundle"); err != nil || !found || string(v) != "v1" {
+			t.Errorf("Expected %s to carry the metadata, got (%s) %v %v\n", name, v, found, err)
+		}
+	}
+	if string(entries["readme.txt"]) != "not an image" || !bytes.Equal(entries["fake.png.orig"], bs) {
+		t.Errorf("Expected the other entries unchanged\n")
+	}
+
+	// Negative test cases.
+	if _, err := EmbedInZip(bs, "Bundle", "v1"); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+
+	buf.Reset()
+	zw = zip.NewWriter(buf)
+	w, err := zw.Create("broken.png")
+	fatalIfError(t, err)
+	_, err = w.Write([]byte("not a png"))
+	fatalIfError(t, err)
+	fatalIfError(t, zw.Close())
+	if _, err := EmbedInZip(buf.Bytes(), "Bundle", "v1"); err == nil {
+		t.Errorf("Expected error, got nil!\n")
+	}
+}